@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// attemptReview часть ответа GET /tests/attempts/:id, нужная для проверки ключа
+type attemptReview struct {
+	Review []struct {
+		Question struct {
+			CorrectAnswer *int `json:"correct_answer"`
+		} `json:"question"`
+		IsCorrect *bool `json:"is_correct"`
+	} `json:"review"`
+}
+
+// createRetakeTest создает тест с одним вопросом, показом ответов и двумя попытками
+func createRetakeTest(t *testing.T, f *routeFixture) (*models.Test, *models.TestQuestion) {
+	t.Helper()
+	test := &models.Test{LessonID: f.ids["lesson"], Title: "Реки", AllowRetake: true, MaxAttempts: 2, ShowCorrectAnswers: true}
+	f.create(t, test)
+	question := &models.TestQuestion{TestID: &test.ID, Question: "Самая длинная река России?", Options: `["Обь","Лена"]`, CorrectAnswer: 1}
+	f.create(t, question)
+	return test, question
+}
+
+// submitAttempt проходит тест от имени владельца и возвращает ID попытки
+func submitAttempt(t *testing.T, f *routeFixture, testID, questionID uint, answer int) uint {
+	t.Helper()
+	body := fmt.Sprintf(`{"answers":"{\"%d\":%d}"}`, questionID, answer)
+	code, resp := f.do("POST", fmt.Sprintf("/api/v1/tests/%d/attempt", testID), body, "owner")
+	if code != http.StatusCreated {
+		t.Fatalf("попытка: код %d (%s)", code, resp)
+	}
+	var attempt models.TestAttempt
+	if err := json.Unmarshal([]byte(resp), &attempt); err != nil {
+		t.Fatalf("ответ не JSON: %s", resp)
+	}
+	return attempt.ID
+}
+
+// getReview возвращает разбор попытки для владельца
+func getReview(t *testing.T, f *routeFixture, attemptID uint) attemptReview {
+	t.Helper()
+	code, resp := f.do("GET", fmt.Sprintf("/api/v1/tests/attempts/%d", attemptID), "", "owner")
+	if code != http.StatusOK {
+		t.Fatalf("разбор: код %d (%s)", code, resp)
+	}
+	var review attemptReview
+	if err := json.Unmarshal([]byte(resp), &review); err != nil || len(review.Review) != 1 {
+		t.Fatalf("неожиданный разбор: %s", resp)
+	}
+	return review
+}
+
+// TestAttemptReviewHidesKeyWhileRetakeAllowed проверяет, что ключ ответов не виден,
+// пока студент может пересдать тест, и открывается после последней попытки
+func TestAttemptReviewHidesKeyWhileRetakeAllowed(t *testing.T) {
+	f := newRouteFixture(t)
+	test, question := createRetakeTest(t, f)
+
+	first := submitAttempt(t, f, test.ID, question.ID, 0)
+	review := getReview(t, f, first)
+	if review.Review[0].Question.CorrectAnswer != nil || review.Review[0].IsCorrect != nil {
+		t.Fatal("ключ ответов показан, хотя осталась попытка")
+	}
+
+	code, resp := f.do("GET", fmt.Sprintf("/api/v1/tests/%d", test.ID), "", "owner")
+	var view models.TestStudentView
+	if code != http.StatusOK || json.Unmarshal([]byte(resp), &view) != nil || len(view.Questions) != 1 {
+		t.Fatalf("тест: код %d (%s)", code, resp)
+	}
+	if view.Questions[0].CorrectAnswer != nil {
+		t.Fatal("ключ ответов показан в тесте, хотя осталась попытка")
+	}
+
+	// Пересдача после разбора
+	second := submitAttempt(t, f, test.ID, question.ID, 1)
+	for _, id := range []uint{first, second} {
+		review := getReview(t, f, id)
+		if key := review.Review[0].Question.CorrectAnswer; key == nil || *key != 1 {
+			t.Errorf("попытка %d: ключ ответов не показан после последней попытки", id)
+		}
+	}
+}
+
+// TestAttemptReviewShowsKeyAfterClose проверяет показ ключа после закрытия сдачи,
+// даже если число попыток не ограничено
+func TestAttemptReviewShowsKeyAfterClose(t *testing.T) {
+	f := newRouteFixture(t)
+	test, question := createRetakeTest(t, f)
+	attemptID := submitAttempt(t, f, test.ID, question.ID, 0)
+	if review := getReview(t, f, attemptID); review.Review[0].Question.CorrectAnswer != nil {
+		t.Fatal("ключ ответов показан до закрытия сдачи")
+	}
+
+	closed := time.Now().Add(-time.Minute)
+	if err := f.db.Model(test).Updates(map[string]interface{}{"max_attempts": 0, "closes_at": closed}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if review := getReview(t, f, attemptID); review.Review[0].Question.CorrectAnswer == nil {
+		t.Fatal("ключ ответов не показан после закрытия сдачи")
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestEmailCaseInsensitive проверяет, что email сохраняется в нижнем регистре
+// и вход не зависит от регистра
+func TestEmailCaseInsensitive(t *testing.T) {
+	f := newRouteFixture(t)
+
+	register := `{"name":"Иван","email":"Ivan@Example.COM","password":"secret123"}`
+	if code, body := f.do("POST", "/api/v1/auth/register", register, ""); code != http.StatusCreated {
+		t.Fatalf("регистрация: код %d (%s)", code, body)
+	}
+	var email string
+	f.db.Table("users").Where("name = ?", "Иван").Pluck("email", &email)
+	if email != "ivan@example.com" {
+		t.Fatalf("email сохранен как %q", email)
+	}
+
+	duplicate := `{"name":"Иван","email":"IVAN@example.com","password":"secret123"}`
+	if code, body := f.do("POST", "/api/v1/auth/register", duplicate, ""); code != http.StatusBadRequest {
+		t.Fatalf("повторная регистрация в другом регистре: код %d (%s)", code, body)
+	}
+	login := `{"email":"iVaN@eXample.com","password":"secret123"}`
+	if code, body := f.do("POST", "/api/v1/auth/login", login, ""); code != http.StatusOK {
+		t.Fatalf("вход в другом регистре: код %d (%s)", code, body)
+	}
+}
+
+// TestUpdateUserReportsSaveError проверяет, что неудачное сохранение пользователя
+// не отвечает успехом и не меняет роль
+func TestUpdateUserReportsSaveError(t *testing.T) {
+	f := newRouteFixture(t)
+	url := fmt.Sprintf("/api/v1/admin/users/%d", f.ids["user"])
+	body := `{"email":"other@example.com","role":"teacher"}`
+	if code, resp := f.do("PUT", url, body, "admin"); code != http.StatusInternalServerError {
+		t.Fatalf("занятый email: код %d (%s), ожидался 500", code, resp)
+	}
+	var role string
+	f.db.Table("users").Where("id = ?", f.ids["user"]).Pluck("role", &role)
+	if role != "student" {
+		t.Fatalf("роль изменилась при ошибке: %s", role)
+	}
+}
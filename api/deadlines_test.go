@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestCreateDeadlineExtensionUnknownUser проверяет, что продление для несуществующего
+// пользователя не сохраняется
+func TestCreateDeadlineExtensionUnknownUser(t *testing.T) {
+	f := newRouteFixture(t)
+	body := func(userID uint) string {
+		return fmt.Sprintf(`{"assignment_type":"test","assignment_id":%d,"user_id":%d,"due_at":"2030-01-01T00:00:00Z"}`, f.ids["test"], userID)
+	}
+
+	if code, resp := f.do("POST", "/api/v1/admin/extensions", body(missingID), "admin"); code != http.StatusNotFound {
+		t.Fatalf("несуществующий пользователь: код %d (%s), ожидался 404", code, resp)
+	}
+	var stored int64
+	f.db.Table("deadline_extensions").Where("user_id = ?", missingID).Count(&stored)
+	if stored != 0 {
+		t.Fatal("продление для несуществующего пользователя сохранено")
+	}
+	if code, resp := f.do("POST", "/api/v1/admin/extensions", body(f.ids["user"]), "admin"); code != http.StatusOK {
+		t.Fatalf("продление студенту: код %d (%s)", code, resp)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestGradeZeroAndStoredScale проверяет, что нулевая оценка принимается,
+// а обновление без scale сохраняет шкалу оценки
+func TestGradeZeroAndStoredScale(t *testing.T) {
+	f := newRouteFixture(t)
+
+	body := fmt.Sprintf(`{"user_id":%d,"test_id":%d,"grade":0,"scale":"percent"}`, f.ids["user"], f.ids["test"])
+	code, resp := f.do("POST", "/api/v1/admin/tests/grades", body, "teacherIn")
+	var grade models.TestGrade
+	if code != http.StatusOK || json.Unmarshal([]byte(resp), &grade) != nil {
+		t.Fatalf("нулевая оценка: код %d (%s)", code, resp)
+	}
+	if grade.Grade != 0 || grade.Scale != models.GradeScalePercent {
+		t.Fatalf("нулевая оценка сохранена как %v по шкале %q", grade.Grade, grade.Scale)
+	}
+
+	url := fmt.Sprintf("/api/v1/admin/tests/grades/%d", f.ids["testGrade"])
+	code, resp = f.do("PUT", url, `{"grade":40}`, "teacherIn")
+	if code != http.StatusOK || json.Unmarshal([]byte(resp), &grade) != nil {
+		t.Fatalf("обновление без шкалы: код %d (%s)", code, resp)
+	}
+	if grade.Grade != 40 || grade.Scale != models.GradeScalePercent {
+		t.Fatalf("после обновления оценка %v по шкале %q, ожидалось 40 по percent", grade.Grade, grade.Scale)
+	}
+
+	for _, body := range []string{`{}`, `{"grade":-1}`, `{"grade":101}`, `{"grade":6,"scale":"five"}`} {
+		if code, resp := f.do("PUT", url, body, "teacherIn"); code != http.StatusBadRequest {
+			t.Errorf("%s: код %d (%s), ожидался 400", body, code, resp)
+		}
+	}
+}
+
+// TestUserTestGradesShape проверяет, что /grades/tests возвращает массив оценок,
+// а /grades/tests/results - итоги и по неоцененным тестам
+func TestUserTestGradesShape(t *testing.T) {
+	f := newRouteFixture(t)
+	ungraded := &models.Test{LessonID: f.ids["lesson"], Title: "Без оценки"}
+	f.create(t, ungraded)
+	now := time.Now()
+	f.create(t, &models.TestAttempt{UserID: f.ids["user"], TestID: ungraded.ID, Answers: "{}", Score: 80, Status: models.AttemptSubmitted, SubmittedAt: &now})
+
+	code, resp := f.do("GET", "/api/v1/grades/tests", "", "owner")
+	var grades []models.TestGrade
+	if code != http.StatusOK || json.Unmarshal([]byte(resp), &grades) != nil {
+		t.Fatalf("оценки: код %d (%s)", code, resp)
+	}
+	if len(grades) != 1 || grades[0].ID != f.ids["testGrade"] || grades[0].Grade != 5 {
+		t.Fatalf("оценки: %s", resp)
+	}
+
+	code, resp = f.do("GET", "/api/v1/grades/tests/results", "", "owner")
+	var results []struct {
+		TestID         uint     `json:"test_id"`
+		Graded         bool     `json:"graded"`
+		EffectiveScore *float64 `json:"effective_score"`
+	}
+	if code != http.StatusOK || json.Unmarshal([]byte(resp), &results) != nil || len(results) != 2 {
+		t.Fatalf("итоги: код %d (%s)", code, resp)
+	}
+	last := results[1]
+	if last.TestID != ungraded.ID || last.Graded || last.EffectiveScore == nil || *last.EffectiveScore != 80 {
+		t.Fatalf("итог неоцененного теста: %+v", last)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// assignLesson назначает урок группе от имени пользователя с публикацией в publishAt
+func assignLesson(t *testing.T, f *routeFixture, user string, groupID uint, publishAt time.Time) {
+	t.Helper()
+	body := fmt.Sprintf(`{"content_type":"lesson","content_id":%d,"publish_at":%q}`, f.ids["lesson"], publishAt.Format(time.RFC3339))
+	code, resp := f.do("PUT", fmt.Sprintf("/api/v1/admin/groups/%d/content", groupID), body, user)
+	if code != http.StatusOK {
+		t.Fatalf("назначение урока от %s: код %d (%s)", user, code, resp)
+	}
+}
+
+// visibleLessonContent возвращает коды ответов на урок, его тест и практику для пользователя
+func visibleLessonContent(f *routeFixture, user string) [3]int {
+	var codes [3]int
+	for i, path := range []string{
+		fmt.Sprintf("/api/v1/lessons/%d", f.ids["lesson"]),
+		fmt.Sprintf("/api/v1/tests/%d", f.ids["test"]),
+		fmt.Sprintf("/api/v1/practices/%d", f.ids["practice"]),
+	} {
+		codes[i], _ = f.do("GET", path, "", user)
+	}
+	return codes
+}
+
+// TestTeacherAssignmentScope проверяет, что отложенная публикация урока скрывает его тесты
+// и практики, а назначение преподавателя не скрывает урок в чужих группах
+func TestTeacherAssignmentScope(t *testing.T) {
+	f := newRouteFixture(t)
+	assignLesson(t, f, "teacherIn", f.ids["groupIn"], time.Now().Add(24*time.Hour))
+
+	hidden := [3]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}
+	visible := [3]int{http.StatusOK, http.StatusOK, http.StatusOK}
+	if got := visibleLessonContent(f, "owner"); got != hidden {
+		t.Errorf("студент группы до публикации: коды %v, ожидались %v", got, hidden)
+	}
+	if got := visibleLessonContent(f, "other"); got != visible {
+		t.Errorf("студент чужой группы: коды %v, ожидались %v", got, visible)
+	}
+
+	code, _ := f.do("PUT", fmt.Sprintf("/api/v1/admin/groups/%d/content", f.ids["groupOut"]),
+		fmt.Sprintf(`{"content_type":"lesson","content_id":%d}`, f.ids["lesson"]), "teacherIn")
+	if code != http.StatusNotFound {
+		t.Errorf("назначение в чужую группу: код %d, ожидался 404", code)
+	}
+}
+
+// TestAdminAssignmentScope проверяет, что назначение администратора скрывает урок
+// со всеми материалами от студентов других групп
+func TestAdminAssignmentScope(t *testing.T) {
+	f := newRouteFixture(t)
+	assignLesson(t, f, "admin", f.ids["groupIn"], time.Now().Add(-time.Hour))
+
+	if got, want := visibleLessonContent(f, "owner"), [3]int{http.StatusOK, http.StatusOK, http.StatusOK}; got != want {
+		t.Errorf("студент группы: коды %v, ожидались %v", got, want)
+	}
+	if got, want := visibleLessonContent(f, "other"), [3]int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}; got != want {
+		t.Errorf("студент чужой группы: коды %v, ожидались %v", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"geografi-cheb/backend/models"
 	"geografi-cheb/backend/pkg"
 	"net/http"
 	"strings"
@@ -8,8 +9,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware проверяет JWT токен в заголовке Authorization
-func AuthMiddleware() gin.HandlerFunc {
+// passwordChangeAllowed - маршруты, доступные до смены временного пароля
+var passwordChangeAllowed = map[string]bool{
+	"/api/v1/auth/change-password": true,
+	"/api/v1/users/me":             true,
+	"/api/v1/auth/logout":          true,
+	"/api/v1/auth/logout-all":      true,
+}
+
+// twoFactorSetupAllowed - маршруты, доступные администратору до обязательной настройки 2FA
+var twoFactorSetupAllowed = map[string]bool{
+	"/api/v1/auth/2fa":        true,
+	"/api/v1/auth/2fa/setup":  true,
+	"/api/v1/auth/2fa/enable": true,
+	"/api/v1/auth/logout":     true,
+	"/api/v1/auth/logout-all": true,
+	"/api/v1/users/me":        true,
+}
+
+// AuthMiddleware проверяет JWT токен в заголовке Authorization, что он не отозван
+// и учетная запись активна. Роль берется из текущих данных пользователя, а не из токена.
+func AuthMiddleware(users *pkg.UserCache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -34,25 +54,54 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Токен отозван, если пользователь удален, версия его токенов изменилась
+		// (смена роли или пароля, выход на всех устройствах) или сессия завершена
+		user, err := users.GetActive(claims.UserID, claims.SessionID)
+		if err != nil || user.TokenVersion != claims.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Сессия завершена, войдите заново", "code": "token_revoked"})
+			c.Abort()
+			return
+		}
+		if user.IsSuspended() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Учетная запись заблокирована", "code": "account_suspended"})
+			c.Abort()
+			return
+		}
+
+		// С временным паролем доступна только его смена
+		if claims.MustChangePassword && !passwordChangeAllowed[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Необходимо сменить временный пароль", "code": "password_change_required"})
+			c.Abort()
+			return
+		}
+
+		// Если 2FA обязательна, до ее настройки доступна только она
+		if claims.TwoFactorSetupRequired && !twoFactorSetupAllowed[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Необходимо настроить двухфакторную аутентификацию", "code": "two_factor_setup_required"})
+			c.Abort()
+			return
+		}
+
 		// Сохраняем данные пользователя в контекст
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
+		c.Set("user_id", user.ID)
+		c.Set("user_email", user.Email)
+		c.Set("user_role", user.Role)
+		c.Set("session_id", claims.SessionID)
 
 		c.Next()
 	}
 }
 
-// AdminMiddleware проверяет, что пользователь является администратором
-func AdminMiddleware() gin.HandlerFunc {
+// RequirePermission проверяет, что у роли пользователя есть указанное право
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("user_role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Доступ запрещен. Требуются права администратора"})
+		role, _ := c.Get("user_role")
+		roleName, _ := role.(string)
+		if !models.RoleHasPermission(roleName, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Доступ запрещен. Недостаточно прав"})
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
 }
-
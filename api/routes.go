@@ -1,20 +1,17 @@
 package api
 
 import (
-	"geografi-cheb/backend/config"
 	"geografi-cheb/backend/internal/handlers"
+	"geografi-cheb/backend/models"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"gorm.io/gorm"
 )
 
-// SetupRoutes настраивает все маршруты API
-func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
-	// Инициализация обработчиков
-	h := handlers.NewHandlers(db, cfg)
-
+// SetupRoutes настраивает все маршруты API.
+// Фоновые задачи обработчиков (StartAttemptExpiry, StartAuthCleanup) запускает вызывающий код.
+func SetupRoutes(router *gin.Engine, h *handlers.Handlers) {
 	// Swagger документация
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -26,17 +23,38 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		{
 			auth.POST("/register", h.Register)
 			auth.POST("/login", h.Login)
+			auth.POST("/forgot-password", h.ForgotPassword)
+			auth.POST("/reset-password", h.ResetPassword)
+			auth.POST("/refresh", h.RefreshToken)
+			auth.POST("/2fa/verify", h.VerifyTwoFactor)
+			auth.POST("/setup", h.Setup)
 		}
 
 		// Защищенные эндпоинты (требуют авторизации)
 		protected := v1.Group("")
-		protected.Use(AuthMiddleware())
+		protected.Use(AuthMiddleware(h.Users))
 		{
 			// Загрузка файлов
 			upload := protected.Group("/upload")
 			{
 				upload.POST("/file", h.UploadFile)
 			}
+			// Смена пароля (доступна и с временным паролем)
+			protected.POST("/auth/change-password", h.ChangePassword)
+			// Завершение текущей сессии и всех сессий пользователя
+			protected.POST("/auth/logout", h.Logout)
+			protected.POST("/auth/logout-all", h.LogoutAll)
+
+			// Двухфакторная аутентификация (TOTP)
+			twoFactor := protected.Group("/auth/2fa")
+			{
+				twoFactor.GET("", h.GetTwoFactorStatus)
+				twoFactor.POST("/setup", h.SetupTwoFactor)
+				twoFactor.POST("/enable", h.EnableTwoFactor)
+				twoFactor.POST("/disable", h.DisableTwoFactor)
+				twoFactor.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+			}
+
 			// Пользователи
 			users := protected.Group("/users")
 			{
@@ -51,14 +69,27 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 				lessons.GET("/:id", h.GetLesson)
 			}
 
+			// Доклады
+			reports := protected.Group("/reports")
+			{
+				reports.GET("", h.GetReports)
+				reports.POST("", h.CreateReport)
+				reports.GET("/:id", h.GetReport)
+				reports.PUT("/:id", h.UpdateReport)
+				reports.DELETE("/:id", h.DeleteReport)
+			}
+
 			// Тесты
 			tests := protected.Group("/tests")
 			{
 				tests.GET("", h.GetTests)
 				tests.GET("/:id", h.GetTest)
 				tests.POST("/:id/attempt", h.CreateTestAttempt)
+				tests.POST("/:id/start", h.StartTestAttempt)
 				tests.GET("/attempts", h.GetUserTestAttempts)
 				tests.GET("/attempts/:id", h.GetTestAttempt)
+				tests.PUT("/attempts/:id/answers", h.SaveTestAttemptAnswers)
+				tests.POST("/attempts/:id/submit", h.SubmitTestAttempt)
 			}
 
 			// Практические задания
@@ -85,27 +116,72 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 				videos.GET("/:id", h.GetVideo)
 			}
 
+			// Мои группы
+			protected.GET("/groups", h.GetMyGroups)
+			protected.POST("/groups/join", h.JoinGroup)
+
 			// Оценки (только просмотр для студентов)
 			grades := protected.Group("/grades")
 			{
 				grades.GET("/tests", h.GetUserTestGrades)
+				grades.GET("/tests/results", h.GetUserTestResults)
 				grades.GET("/practices", h.GetUserPracticeGrades)
+				grades.GET("/reports", h.GetUserReportGrades)
+				grades.GET("/gradebook", h.GetMyGradebook)
 			}
 
-			// Админские эндпоинты
+			// Эндпоинты управления: доступ определяется правами роли (admin, teacher)
 			admin := protected.Group("/admin")
-			admin.Use(AdminMiddleware())
 			{
+				content := RequirePermission(models.PermManageContent)
+				grading := RequirePermission(models.PermGrade)
+
 				// Управление пользователями
-				adminUsers := admin.Group("/users")
+				adminUsers := admin.Group("/users", RequirePermission(models.PermManageUsers))
 				{
 					adminUsers.GET("", h.GetAllUsers)
+					adminUsers.POST("/import", h.ImportUsers)
 					adminUsers.PUT("/:id", h.UpdateUser)
+					adminUsers.PUT("/:id/status", h.SetUserStatus)
+					adminUsers.POST("/:id/unlock", h.UnlockUser)
+					adminUsers.DELETE("/:id/two-factor", h.ResetUserTwoFactor)
 					adminUsers.DELETE("/:id", h.DeleteUser)
 				}
 
+				// Журнал безопасности
+				admin.GET("/security-events", RequirePermission(models.PermManageUsers), h.GetSecurityEvents)
+
+				// Системные настройки
+				adminSettings := admin.Group("/settings", RequirePermission(models.PermManageSettings))
+				{
+					adminSettings.GET("", h.GetSettings)
+					adminSettings.PUT("", h.UpdateSettings)
+				}
+
+				// Учебные группы
+				adminGroups := admin.Group("/groups", RequirePermission(models.PermManageGroups))
+				{
+					adminGroups.GET("", h.GetGroups)
+					adminGroups.POST("", h.CreateGroup)
+					adminGroups.GET("/:id", h.GetGroup)
+					adminGroups.PUT("/:id", h.UpdateGroup)
+					adminGroups.DELETE("/:id", h.DeleteGroup)
+					adminGroups.PUT("/:id/teachers", h.SetGroupTeachers)
+					adminGroups.POST("/:id/members", h.AddGroupMembers)
+					adminGroups.DELETE("/:id/members/:userId", h.RemoveGroupMember)
+					adminGroups.POST("/:id/join-code", h.RegenerateJoinCode)
+				}
+
+				// Материалы групп: администратор - любой группы, преподаватель - своих
+				groupContent := admin.Group("/groups/:id/content", content)
+				{
+					groupContent.GET("", h.GetGroupContent)
+					groupContent.PUT("", h.AssignGroupContent)
+					groupContent.DELETE("/:contentId", h.RemoveGroupContent)
+				}
+
 				// Управление уроками
-				adminLessons := admin.Group("/lessons")
+				adminLessons := admin.Group("/lessons", content)
 				{
 					adminLessons.POST("", h.CreateLesson)
 					adminLessons.PUT("/:id", h.UpdateLesson)
@@ -113,32 +189,84 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 				}
 
 				// Управление тестами
-				adminTests := admin.Group("/tests")
+				adminTests := admin.Group("/tests", content)
 				{
 					adminTests.POST("", h.CreateTest)
 					adminTests.PUT("/:id", h.UpdateTest)
 					adminTests.DELETE("/:id", h.DeleteTest)
-					adminTests.GET("/attempts", h.GetAllTestAttempts)
-					adminTests.DELETE("/attempts/:id", h.DeleteTestAttempt)
-					adminTests.POST("/grades", h.CreateTestGrade)
-					adminTests.PUT("/grades/:id", h.UpdateTestGrade)
-					adminTests.DELETE("/grades/:id", h.DeleteTestGrade)
+				}
+
+				// Проверка тестов
+				gradeTests := admin.Group("/tests", grading)
+				{
+					gradeTests.GET("/:id/question-stats", h.GetTestQuestionStats)
+					gradeTests.GET("/:id/item-analysis", h.GetTestItemAnalysis)
+					gradeTests.GET("/:id/regrade-log", h.GetTestRegradeLog)
+					gradeTests.POST("/:id/regrade", h.RegradeTest)
+					gradeTests.GET("/attempts", h.GetAllTestAttempts)
+					gradeTests.DELETE("/attempts/:id", h.DeleteTestAttempt)
+					gradeTests.POST("/attempts/:id/regrade", h.RegradeTestAttempt)
+					gradeTests.POST("/grades", h.CreateTestGrade)
+					gradeTests.PUT("/grades/:id", h.UpdateTestGrade)
+					gradeTests.DELETE("/grades/:id", h.DeleteTestGrade)
+				}
+
+				// Банки вопросов
+				adminPools := admin.Group("/pools", content)
+				{
+					adminPools.GET("", h.GetQuestionPools)
+					adminPools.POST("", h.CreateQuestionPool)
+					adminPools.GET("/:id", h.GetQuestionPool)
+					adminPools.PUT("/:id", h.UpdateQuestionPool)
+					adminPools.DELETE("/:id", h.DeleteQuestionPool)
+					adminPools.POST("/:id/questions", h.CreatePoolQuestion)
+					adminPools.PUT("/:id/questions/:questionId", h.UpdatePoolQuestion)
+					adminPools.DELETE("/:id/questions/:questionId", h.DeletePoolQuestion)
 				}
 
 				// Управление практическими заданиями
-				adminPractices := admin.Group("/practices")
+				adminPractices := admin.Group("/practices", content)
 				{
 					adminPractices.POST("", h.CreatePractice)
 					adminPractices.PUT("/:id", h.UpdatePractice)
 					adminPractices.DELETE("/:id", h.DeletePractice)
-					adminPractices.GET("/submits", h.GetAllPracticeSubmits)
-					adminPractices.POST("/grades", h.CreatePracticeGrade)
-					adminPractices.PUT("/grades/:id", h.UpdatePracticeGrade)
-					adminPractices.DELETE("/grades/:id", h.DeletePracticeGrade)
+				}
+
+				// Проверка практических заданий
+				gradePractices := admin.Group("/practices", grading)
+				{
+					gradePractices.GET("/submits", h.GetAllPracticeSubmits)
+					gradePractices.PUT("/submits/:id/status", h.UpdatePracticeSubmitStatus)
+					gradePractices.POST("/submits/:id/comments", h.CreatePracticeSubmitComment)
+					gradePractices.POST("/grades", h.CreatePracticeGrade)
+					gradePractices.PUT("/grades/:id", h.UpdatePracticeGrade)
+					gradePractices.DELETE("/grades/:id", h.DeletePracticeGrade)
+				}
+
+				// Журнал оценок
+				admin.GET("/gradebook", grading, h.GetGradebook)
+				admin.GET("/gradebook/export", grading, h.ExportGradebook)
+
+				// Продление сроков сдачи
+				adminExtensions := admin.Group("/extensions", grading)
+				{
+					adminExtensions.GET("", h.GetDeadlineExtensions)
+					adminExtensions.POST("", h.CreateDeadlineExtension)
+					adminExtensions.DELETE("/:id", h.DeleteDeadlineExtension)
+				}
+
+				// Доклады
+				adminReports := admin.Group("/reports", grading)
+				{
+					adminReports.GET("", h.GetAllReports)
+					adminReports.PUT("/:id/feedback", h.SetReportFeedback)
+					adminReports.POST("/grades", h.CreateReportGrade)
+					adminReports.PUT("/grades/:id", h.UpdateReportGrade)
+					adminReports.DELETE("/grades/:id", h.DeleteReportGrade)
 				}
 
 				// Управление фактами
-				adminFacts := admin.Group("/facts")
+				adminFacts := admin.Group("/facts", content)
 				{
 					adminFacts.POST("", h.CreateFact)
 					adminFacts.PUT("/:id", h.UpdateFact)
@@ -146,7 +274,7 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
 				}
 
 				// Управление видео
-				adminVideos := admin.Group("/videos")
+				adminVideos := admin.Group("/videos", content)
 				{
 					adminVideos.POST("", h.CreateVideo)
 					adminVideos.PUT("/:id", h.UpdateVideo)
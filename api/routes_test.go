@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"geografi-cheb/backend/config"
+	"geografi-cheb/backend/internal/handlers"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// missingID - ID, под которым в тестовой базе нет записей
+const missingID = 999999
+
+var testDBCounter int64
+
+// routeFixture тестовая база с пользователями разных ролей и работами студента owner
+type routeFixture struct {
+	db     *gorm.DB
+	router *gin.Engine
+	users  map[string]*models.User
+	tokens map[string]string // Токены по имени пользователя
+	ids    map[string]uint   // ID работ владельца
+}
+
+// fixtureUsers пользователи тестовой базы: имя и роль
+var fixtureUsers = []struct{ name, role string }{
+	{"admin", models.RoleAdmin},
+	{"teacherIn", models.RoleTeacher},
+	{"teacherOut", models.RoleTeacher},
+	{"owner", models.RoleStudent},
+	{"other", models.RoleStudent},
+}
+
+// newRouteFixture создает отдельную базу в памяти и маршрутизатор.
+// Преподаватель teacherIn ведет группу владельца, teacherOut - группу другого студента.
+func newRouteFixture(t *testing.T) *routeFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dsn := fmt.Sprintf("file:routes%d?mode=memory&cache=shared", atomic.AddInt64(&testDBCounter, 1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("открытие базы: %v", err)
+	}
+	// Миграции из db.RunMigrations используют SQL Postgres, поэтому создаем только таблицы
+	if err := db.AutoMigrate(
+		&models.User{}, &models.Session{}, &models.Group{}, &models.GroupContent{}, &models.Lesson{}, &models.Video{},
+		&models.Test{}, &models.QuestionPool{}, &models.TestQuestion{}, &models.TestPoolRule{},
+		&models.TestAttempt{}, &models.TestAttemptAnswer{}, &models.TestRegradeLog{}, &models.TestGrade{},
+		&models.Practice{}, &models.PracticeSubmit{}, &models.PracticeSubmitComment{}, &models.PracticeGrade{},
+		&models.Report{}, &models.ReportGrade{}, &models.DeadlineExtension{},
+	); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+
+	cfg := &config.Config{JWTSecret: "test-secret", AccessTokenTTL: time.Hour, MailDriver: config.MailDriverLog}
+	router := gin.New()
+	h, err := handlers.NewHandlers(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetupRoutes(router, h)
+
+	f := &routeFixture{db: db, router: router, users: map[string]*models.User{}, tokens: map[string]string{}, ids: map[string]uint{}}
+	for _, u := range fixtureUsers {
+		user := &models.User{Name: u.name, Email: u.name + "@example.com", Password: "-", Role: u.role}
+		f.create(t, user)
+		f.users[u.name] = user
+		f.tokens[u.name] = f.issueToken(t, user)
+	}
+	groupIn := &models.Group{Name: "G", Teachers: []models.User{*f.users["teacherIn"]}, Members: []models.User{*f.users["owner"]}}
+	f.create(t, groupIn)
+	groupOut := &models.Group{Name: "H", Teachers: []models.User{*f.users["teacherOut"]}, Members: []models.User{*f.users["other"]}}
+	f.create(t, groupOut)
+	f.ids["groupIn"] = groupIn.ID
+	f.ids["groupOut"] = groupOut.ID
+	f.seed(t)
+	return f
+}
+
+// issueToken открывает сессию пользователя и выдает access-токен
+func (f *routeFixture) issueToken(t *testing.T, user *models.User) string {
+	t.Helper()
+	session := &models.Session{UserID: user.ID, RefreshHash: "refresh-" + user.Name, ExpiresAt: time.Now().Add(time.Hour)}
+	f.create(t, session)
+	token, err := pkg.GenerateToken(user, session.ID, false)
+	if err != nil {
+		t.Fatalf("токен: %v", err)
+	}
+	return token
+}
+
+// seed создает работы студента owner
+func (f *routeFixture) seed(t *testing.T) {
+	t.Helper()
+	ownerID := f.users["owner"].ID
+
+	lesson := &models.Lesson{Number: 1, Topic: "Рельеф"}
+	f.create(t, lesson)
+	test := &models.Test{LessonID: lesson.ID, Title: "Тест"}
+	f.create(t, test)
+	practice := &models.Practice{LessonID: lesson.ID, Title: "Практика"}
+	f.create(t, practice)
+
+	now := time.Now()
+	attempt := &models.TestAttempt{UserID: ownerID, TestID: test.ID, Answers: "{}", Status: models.AttemptSubmitted, SubmittedAt: &now}
+	f.create(t, attempt)
+	active := &models.TestAttempt{UserID: ownerID, TestID: test.ID, Status: models.AttemptInProgress, StartedAt: &now}
+	f.create(t, active)
+	submit := &models.PracticeSubmit{UserID: ownerID, PracticeID: practice.ID, FileURL: "/uploads/work.pdf"}
+	f.create(t, submit)
+	report := &models.Report{UserID: ownerID, LessonID: lesson.ID, Title: "Доклад", FileURL: "/uploads/report.pdf"}
+	f.create(t, report)
+	draft := &models.Report{UserID: ownerID, LessonID: lesson.ID, Title: "Черновик", FileURL: "/uploads/draft.pdf"}
+	f.create(t, draft)
+	testGrade := &models.TestGrade{UserID: ownerID, TestID: test.ID, AttemptID: attempt.ID, Grade: 5}
+	f.create(t, testGrade)
+	practiceGrade := &models.PracticeGrade{UserID: ownerID, PracticeID: practice.ID, SubmitID: submit.ID, Grade: 5, RawGrade: 5}
+	f.create(t, practiceGrade)
+	reportGrade := &models.ReportGrade{UserID: ownerID, ReportID: report.ID, Grade: 5}
+	f.create(t, reportGrade)
+	extension := &models.DeadlineExtension{AssignmentType: models.AssignmentTest, AssignmentID: test.ID, UserID: ownerID, DueAt: &now}
+	f.create(t, extension)
+
+	f.ids["user"] = ownerID
+	f.ids["lesson"] = lesson.ID
+	f.ids["test"] = test.ID
+	f.ids["practice"] = practice.ID
+	f.ids["attempt"] = attempt.ID
+	f.ids["active"] = active.ID
+	f.ids["submit"] = submit.ID
+	f.ids["report"] = report.ID
+	f.ids["draft"] = draft.ID
+	f.ids["testGrade"] = testGrade.ID
+	f.ids["practiceGrade"] = practiceGrade.ID
+	f.ids["reportGrade"] = reportGrade.ID
+	f.ids["extension"] = extension.ID
+}
+
+func (f *routeFixture) create(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := f.db.Create(value).Error; err != nil {
+		t.Fatalf("создание %T: %v", value, err)
+	}
+}
+
+// do выполняет запрос от имени пользователя и возвращает код и тело ответа
+func (f *routeFixture) do(method, path, body, user string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+// protectedRoute маршрут с ID работы студента owner в пути
+type protectedRoute struct {
+	method string
+	path   string // Путь с %d на месте ID
+	record string // Ключ routeFixture.ids
+	body   string
+	ok     int            // Код успешного ответа
+	expect map[string]int // Коды для пользователей, не получающих ok
+	// missingAs - пользователь, запрашивающий несуществующий ID.
+	// Ответ должен совпадать с ответом на чужую запись.
+	missingAs string
+}
+
+var (
+	// Работы студента: владелец и проверяющие видят,
+	// чужой студент и преподаватель другой группы получают 404
+	ownedExpect = map[string]int{"other": http.StatusNotFound, "teacherOut": http.StatusNotFound}
+	// Действия только владельца: ответы и завершение попытки
+	ownerOnlyExpect = map[string]int{
+		"other": http.StatusNotFound, "admin": http.StatusNotFound,
+		"teacherIn": http.StatusNotFound, "teacherOut": http.StatusNotFound,
+	}
+	// Маршруты проверки: студентам не хватает прав, преподаватель другой группы получает 404
+	gradingExpect = map[string]int{"owner": http.StatusForbidden, "other": http.StatusForbidden, "teacherOut": http.StatusNotFound}
+)
+
+var reportBody = `{"lesson_id":1,"title":"Доклад о рельефе","file_url":"/uploads/report-v2.pdf"}`
+
+var protectedRoutes = []protectedRoute{
+	{"GET", "/api/v1/users/%d", "user", "", http.StatusOK, ownedExpect, "admin"},
+	{"GET", "/api/v1/tests/attempts/%d", "attempt", "", http.StatusOK, ownedExpect, "admin"},
+	{"PUT", "/api/v1/tests/attempts/%d/answers", "active", `{"answers":"{}"}`, http.StatusOK, ownerOnlyExpect, "owner"},
+	{"POST", "/api/v1/tests/attempts/%d/submit", "active", "", http.StatusOK, ownerOnlyExpect, "owner"},
+	{"GET", "/api/v1/practices/submits/%d", "submit", "", http.StatusOK, ownedExpect, "admin"},
+	{"GET", "/api/v1/reports/%d", "report", "", http.StatusOK, ownedExpect, "admin"},
+	{"PUT", "/api/v1/reports/%d", "draft", reportBody, http.StatusOK, ownedExpect, "admin"},
+	{"DELETE", "/api/v1/reports/%d", "draft", "", http.StatusOK, ownedExpect, "admin"},
+	{"DELETE", "/api/v1/admin/tests/attempts/%d", "attempt", "", http.StatusOK, gradingExpect, "admin"},
+	{"POST", "/api/v1/admin/tests/attempts/%d/regrade", "attempt", "", http.StatusOK, gradingExpect, "admin"},
+	{"PUT", "/api/v1/admin/practices/submits/%d/status", "submit", `{"status":"under_review"}`, http.StatusOK, gradingExpect, "admin"},
+	{"POST", "/api/v1/admin/practices/submits/%d/comments", "submit", `{"text":"Исправьте легенду"}`, http.StatusCreated, gradingExpect, "admin"},
+	{"PUT", "/api/v1/admin/reports/%d/feedback", "report", `{"feedback":"Хорошие источники"}`, http.StatusOK, gradingExpect, "admin"},
+	{"PUT", "/api/v1/admin/tests/grades/%d", "testGrade", `{"grade":4}`, http.StatusOK, gradingExpect, "admin"},
+	{"DELETE", "/api/v1/admin/tests/grades/%d", "testGrade", "", http.StatusOK, gradingExpect, "admin"},
+	{"PUT", "/api/v1/admin/practices/grades/%d", "practiceGrade", `{"grade":4}`, http.StatusOK, gradingExpect, "admin"},
+	{"DELETE", "/api/v1/admin/practices/grades/%d", "practiceGrade", "", http.StatusOK, gradingExpect, "admin"},
+	{"PUT", "/api/v1/admin/reports/grades/%d", "reportGrade", `{"grade":4}`, http.StatusOK, gradingExpect, "admin"},
+	{"DELETE", "/api/v1/admin/reports/grades/%d", "reportGrade", "", http.StatusOK, gradingExpect, "admin"},
+	{"DELETE", "/api/v1/admin/extensions/%d", "extension", "", http.StatusOK, gradingExpect, "admin"},
+}
+
+// TestProtectedRoutesOwnership проверяет доступ к работам по ID:
+// чужая запись отвечает так же, как несуществующая
+func TestProtectedRoutesOwnership(t *testing.T) {
+	for _, route := range protectedRoutes {
+		route := route
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			var foreignBody string
+			for _, u := range fixtureUsers {
+				// Каждому пользователю - свежая база: запрос может изменить или удалить запись
+				f := newRouteFixture(t)
+				want, ok := route.expect[u.name]
+				if !ok {
+					want = route.ok
+				}
+				code, body := f.do(route.method, fmt.Sprintf(route.path, f.ids[route.record]), route.body, u.name)
+				if code != want {
+					t.Errorf("%s: код %d, ожидался %d (%s)", u.name, code, want, body)
+				}
+				if code == http.StatusNotFound {
+					foreignBody = body
+				}
+			}
+
+			f := newRouteFixture(t)
+			code, body := f.do(route.method, fmt.Sprintf(route.path, missingID), route.body, route.missingAs)
+			if code != http.StatusNotFound {
+				t.Errorf("несуществующий ID: код %d, ожидался 404 (%s)", code, body)
+			}
+			if foreignBody == "" {
+				t.Fatal("ни один пользователь не получил 404 на чужую запись")
+			}
+			if body != foreignBody {
+				t.Errorf("ответ на несуществующий ID %s отличается от ответа на чужой %s", body, foreignBody)
+			}
+		})
+	}
+}
+
+// TestProtectedRoutesNotFoundBody проверяет, что 404 не раскрывает ID и причину отказа
+func TestProtectedRoutesNotFoundBody(t *testing.T) {
+	f := newRouteFixture(t)
+	_, body := f.do("GET", fmt.Sprintf("/api/v1/tests/attempts/%d", f.ids["attempt"]), "", "other")
+
+	var resp map[string]string
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("ответ не JSON: %s", body)
+	}
+	if len(resp) != 1 || resp["error"] != "Попытка не найдена" {
+		t.Errorf("неожиданный ответ: %s", body)
+	}
+}
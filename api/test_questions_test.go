@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// createTestBody возвращает тело запроса создания теста с вопросами questions (JSON-массив)
+func createTestBody(f *routeFixture, questions string) string {
+	return fmt.Sprintf(`{"lesson_id":%d,"title":"Реки России","questions":%s}`, f.ids["lesson"], questions)
+}
+
+// TestCreateTestQuestionValidation проверяет ответы на вопросы с некорректным ключом
+func TestCreateTestQuestionValidation(t *testing.T) {
+	f := newRouteFixture(t)
+	tests := []struct {
+		name      string
+		questions string
+		want      int
+	}{
+		{"один правильный ответ", `[{"question":"Самая длинная река?","options":["Обь","Лена"],"correct_answers":[1]}]`, http.StatusCreated},
+		{"несколько правильных у single", `[{"type":"single","question":"Самая длинная река?","options":["Обь","Лена"],"correct_answers":[0,1]}]`, http.StatusBadRequest},
+		{"несколько правильных у multiple", `[{"type":"multiple","question":"Реки Сибири?","options":["Обь","Лена"],"correct_answers":[0,1]}]`, http.StatusCreated},
+		{"числовой без value", `[{"type":"numeric","question":"Глубина Байкала?","answer_key":{"tolerance":10}}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		code, body := f.do("POST", "/api/v1/admin/tests", createTestBody(f, tt.questions), "admin")
+		if code != tt.want {
+			t.Errorf("%s: код %d, ожидался %d (%s)", tt.name, code, tt.want, body)
+		}
+	}
+}
+
+// TestPoolRulesValidation проверяет, что правила одного банка не запрашивают
+// больше вопросов, чем в нем есть, а вопросам банка нужен явный тип
+func TestPoolRulesValidation(t *testing.T) {
+	f := newRouteFixture(t)
+	pool := &models.QuestionPool{LessonID: f.ids["lesson"], Title: "Реки"}
+	f.create(t, pool)
+
+	question := `{"question":"Самая длинная река?","options":["Обь","Лена"],"correct_answer":1}`
+	url := fmt.Sprintf("/api/v1/admin/pools/%d/questions", pool.ID)
+	if code, body := f.do("POST", url, question, "admin"); code != http.StatusBadRequest {
+		t.Fatalf("вопрос банка без типа: код %d (%s)", code, body)
+	}
+	typed := `{"type":"single","question":"Самая длинная река?","options":["Обь","Лена"],"correct_answer":1}`
+	for i := 0; i < 2; i++ {
+		if code, body := f.do("POST", url, typed, "admin"); code != http.StatusCreated {
+			t.Fatalf("вопрос банка: код %d (%s)", code, body)
+		}
+	}
+
+	rules := func(counts ...int) string {
+		parts := make([]string, len(counts))
+		for i, count := range counts {
+			parts[i] = fmt.Sprintf(`{"pool_id":%d,"count":%d}`, pool.ID, count)
+		}
+		return fmt.Sprintf(`{"lesson_id":%d,"title":"Реки","pool_rules":[%s]}`, f.ids["lesson"], strings.Join(parts, ","))
+	}
+	if code, body := f.do("POST", "/api/v1/admin/tests", rules(2, 1), "admin"); code != http.StatusBadRequest {
+		t.Fatalf("правила сверх размера банка: код %d (%s)", code, body)
+	}
+	code, body := f.do("POST", "/api/v1/admin/tests", rules(1, 1), "admin")
+	var test models.Test
+	if code != http.StatusCreated || json.Unmarshal([]byte(body), &test) != nil {
+		t.Fatalf("правила в пределах банка: код %d (%s)", code, body)
+	}
+	if len(test.PoolRules) != 1 || test.PoolRules[0].Count != 2 {
+		t.Fatalf("правила банка не объединены: %+v", test.PoolRules)
+	}
+}
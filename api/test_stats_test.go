@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"testing"
+)
+
+// TestTestStatsGradingScope проверяет, что статистика, анализ вопросов и журнал пересчетов
+// строятся только по попыткам студентов, которых проверяет пользователь
+func TestTestStatsGradingScope(t *testing.T) {
+	f := newRouteFixture(t)
+	testID, attemptID := f.ids["test"], f.ids["attempt"]
+	question := &models.TestQuestion{TestID: &testID, Question: "Высочайшая вершина Урала?", Options: `["Народная","Ямантау"]`}
+	f.create(t, question)
+	f.create(t, &models.TestAttemptAnswer{AttemptID: attemptID, QuestionID: question.ID, Position: 1, Answer: "0", IsCorrect: true, Credit: 1})
+	f.create(t, &models.TestRegradeLog{TestID: testID, AttemptID: attemptID, AdminID: f.users["admin"].ID, OldScore: 0, NewScore: 100})
+
+	count := func(path, user string) int {
+		t.Helper()
+		code, body := f.do("GET", fmt.Sprintf(path, testID), "", user)
+		if code != http.StatusOK {
+			t.Fatalf("%s от %s: код %d (%s)", path, user, code, body)
+		}
+		var rows []json.RawMessage
+		if err := json.Unmarshal([]byte(body), &rows); err != nil {
+			t.Fatalf("%s: ответ не массив: %s", path, body)
+		}
+		return len(rows)
+	}
+	attempts := func(user string) int {
+		t.Helper()
+		code, body := f.do("GET", fmt.Sprintf("/api/v1/admin/tests/%d/item-analysis", testID), "", user)
+		var report struct {
+			Attempts int `json:"attempts"`
+		}
+		if code != http.StatusOK || json.Unmarshal([]byte(body), &report) != nil {
+			t.Fatalf("анализ вопросов от %s: код %d (%s)", user, code, body)
+		}
+		return report.Attempts
+	}
+
+	for user, want := range map[string]int{"admin": 1, "teacherIn": 1, "teacherOut": 0} {
+		if got := count("/api/v1/admin/tests/%d/question-stats", user); got != want {
+			t.Errorf("статистика вопросов для %s: %d строк, ожидалось %d", user, got, want)
+		}
+		if got := count("/api/v1/admin/tests/%d/regrade-log", user); got != want {
+			t.Errorf("журнал пересчетов для %s: %d записей, ожидалось %d", user, got, want)
+		}
+		if got := attempts(user); got != want {
+			t.Errorf("анализ вопросов для %s: %d попыток, ожидалось %d", user, got, want)
+		}
+	}
+	for _, user := range []string{"owner", "other"} {
+		if code, _ := f.do("POST", fmt.Sprintf("/api/v1/admin/tests/%d/regrade", testID), "", user); code != http.StatusForbidden {
+			t.Errorf("пересчет теста от %s: код %d, ожидался 403", user, code)
+		}
+	}
+}
+
+// TestRegradeTestGradingScope проверяет, что преподаватель пересчитывает
+// только попытки студентов своих групп
+func TestRegradeTestGradingScope(t *testing.T) {
+	f := newRouteFixture(t)
+	testID, attemptID := f.ids["test"], f.ids["attempt"]
+	question := &models.TestQuestion{TestID: &testID, Question: "Высочайшая вершина Урала?", Options: `["Народная","Ямантау"]`}
+	f.create(t, question)
+	// Верный ответ с нулевым баллом: пересчет по ключу его изменит
+	answers := fmt.Sprintf(`{"%d":0}`, question.ID)
+	if err := f.db.Model(&models.TestAttempt{}).Where("id = ?", attemptID).Updates(map[string]interface{}{"answers": answers, "score": 0}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	regrade := func(user string) int {
+		t.Helper()
+		code, body := f.do("POST", fmt.Sprintf("/api/v1/admin/tests/%d/regrade", testID), "", user)
+		var resp struct {
+			Changed int `json:"changed"`
+		}
+		if code != http.StatusOK || json.Unmarshal([]byte(body), &resp) != nil {
+			t.Fatalf("пересчет от %s: код %d (%s)", user, code, body)
+		}
+		return resp.Changed
+	}
+	if changed := regrade("teacherOut"); changed != 0 {
+		t.Fatalf("преподаватель другой группы пересчитал %d попыток", changed)
+	}
+	if changed := regrade("teacherIn"); changed != 1 {
+		t.Fatalf("преподаватель группы пересчитал %d попыток, ожидалась 1", changed)
+	}
+}
@@ -2,16 +2,34 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Небезопасные значения по умолчанию, запрещенные в production
+const (
+	DefaultJWTSecret     = "your-secret-key-change-in-production"
+	DefaultAdminPassword = "admin123" // Пароль, с которым раньше создавался администратор по умолчанию
+)
+
+// minAdminPasswordLength - минимальная длина ADMIN_PASSWORD
+const minAdminPasswordLength = 8
+
+// Способы отправки писем (MAIL_DRIVER)
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverLog  = "log" // Письма пишутся в MAIL_LOG_FILE или в лог; только для разработки
+)
+
 // Config содержит конфигурацию приложения
 type Config struct {
 	Port           string
@@ -20,6 +38,36 @@ type Config struct {
 	Environment    string
 	UploadDir      string   // Директория для загрузки файлов
 	AllowedOrigins []string // Разрешенные источники для CORS
+	TrustedProxies []string // Прокси (IP или CIDR), которым доверяется X-Forwarded-For; пусто - никому
+
+	AccessTokenTTL  time.Duration // Время жизни access-токена
+	RefreshTokenTTL time.Duration // Время жизни сессии (refresh-токена)
+	AuthCacheTTL    time.Duration // Время кэширования пользователя при проверке запросов
+
+	LoginLimiterStore       string        // Хранилище счетчиков попыток входа: memory или postgres
+	LoginMaxAccountFailures int           // Неудачных попыток до блокировки аккаунта
+	LoginMaxIPFailures      int           // Неудачных попыток до блокировки IP
+	LoginFailureWindow      time.Duration // Окно подсчета неудачных попыток
+	LoginLockoutDuration    time.Duration // Длительность блокировки
+
+	TOTPIssuer string // Название сервиса в приложении-аутентификаторе
+
+	// Первый администратор: из ADMIN_EMAIL и ADMIN_PASSWORD или через POST /auth/setup
+	AdminName       string
+	AdminEmail      string
+	AdminPassword   string
+	AdminSetupToken string // Токен первоначальной настройки; пустой - генерируется при запуске
+
+	FrontendURL      string        // Адрес фронтенда для ссылок в письмах
+	PasswordResetTTL time.Duration // Время жизни ссылки восстановления пароля
+
+	MailDriver   string // MailDriverSMTP или MailDriverLog (письма пишутся в MailLogFile или в лог)
+	MailFrom     string
+	MailLogFile  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
 }
 
 // loadEnvFile загружает .env файл, удаляя BOM если он присутствует
@@ -120,13 +168,83 @@ func Load() *Config {
 	return &Config{
 		Port:           getEnv("PORT", "8080"),
 		DatabaseURL:    databaseURL,
-		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTSecret:      getEnv("JWT_SECRET", DefaultJWTSecret),
 		Environment:    getEnv("ENVIRONMENT", "development"),
 		UploadDir:      uploadDir,
 		AllowedOrigins: getAllowedOrigins(),
+		TrustedProxies: getList("TRUSTED_PROXIES"),
+
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		AuthCacheTTL:    getDuration("AUTH_CACHE_TTL", 30*time.Second),
+
+		LoginLimiterStore:       getEnv("LOGIN_LIMITER_STORE", "memory"),
+		LoginMaxAccountFailures: getInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		LoginMaxIPFailures:      getInt("LOGIN_MAX_IP_FAILURES", 50),
+		LoginFailureWindow:      getDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutDuration:    getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "Учебный портал по географии"),
+
+		AdminName:       getEnv("ADMIN_NAME", "Администратор"),
+		AdminEmail:      strings.ToLower(strings.TrimSpace(os.Getenv("ADMIN_EMAIL"))),
+		AdminPassword:   os.Getenv("ADMIN_PASSWORD"),
+		AdminSetupToken: os.Getenv("ADMIN_SETUP_TOKEN"),
+
+		FrontendURL:      strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/"),
+		PasswordResetTTL: getDuration("PASSWORD_RESET_TTL", time.Hour),
+
+		MailDriver:   getEnv("MAIL_DRIVER", MailDriverLog),
+		MailFrom:     getEnv("MAIL_FROM", "noreply@geography.edu"),
+		MailLogFile:  os.Getenv("MAIL_LOG_FILE"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
 	}
 }
 
+// IsProduction проверяет, что приложение запущено в production
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// Validate проверяет конфигурацию перед запуском.
+// В production запрещены JWT-секрет и пароль администратора по умолчанию и отправка писем в лог.
+func (c *Config) Validate() error {
+	if (c.AdminEmail == "") != (c.AdminPassword == "") {
+		return errors.New("ADMIN_EMAIL и ADMIN_PASSWORD задаются вместе")
+	}
+	if c.AdminPassword != "" && len(c.AdminPassword) < minAdminPasswordLength {
+		return fmt.Errorf("ADMIN_PASSWORD должен быть не короче %d символов", minAdminPasswordLength)
+	}
+
+	if c.MailDriver != MailDriverSMTP && c.MailDriver != MailDriverLog {
+		return fmt.Errorf("неизвестный MAIL_DRIVER %q: допустимы %s и %s", c.MailDriver, MailDriverSMTP, MailDriverLog)
+	}
+	if c.MailDriver == MailDriverSMTP && c.SMTPHost == "" {
+		return errors.New("для MAIL_DRIVER=smtp нужно задать SMTP_HOST")
+	}
+
+	if !c.IsProduction() {
+		if c.JWTSecret == DefaultJWTSecret {
+			log.Println("Warning: используется JWT_SECRET по умолчанию, в production запуск будет запрещен")
+		}
+		return nil
+	}
+	if c.JWTSecret == DefaultJWTSecret {
+		return errors.New("в production нужно задать JWT_SECRET: секрет по умолчанию запрещен")
+	}
+	if c.AdminPassword == DefaultAdminPassword {
+		return errors.New("в production нельзя использовать ADMIN_PASSWORD по умолчанию")
+	}
+	// Письма со ссылками сброса пароля не должны оставаться в логе сервера
+	if c.MailDriver != MailDriverSMTP {
+		return errors.New("в production нужен MAIL_DRIVER=smtp")
+	}
+	return nil
+}
+
 func getDatabaseURL() string {
 	// Новая простая логика: подключаемся только по POSTGRESQL_* переменным.
 	host := os.Getenv("POSTGRESQL_HOST")
@@ -204,6 +322,45 @@ func getAllowedOrigins() []string {
 	return origins
 }
 
+// getList читает список значений через запятую
+func getList(key string) []string {
+	var values []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
+
+// getDuration читает длительность в формате time.ParseDuration (например, 30m, 2h)
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: неверное значение %s=%q, используется %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getInt читает положительное целое число
+func getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: неверное значение %s=%q, используется %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
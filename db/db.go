@@ -1,7 +1,10 @@
 package db
 
 import (
+	"fmt"
 	"geografi-cheb/backend/models"
+	"strings"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -27,18 +30,110 @@ func Init(databaseURL string) (*gorm.DB, error) {
 
 // RunMigrations выполняет миграции базы данных
 func RunMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&models.User{},
+		&models.PasswordResetToken{},
+		&models.Session{},
+		&models.LoginThrottle{},
+		&models.SecurityEvent{},
+		&models.RecoveryCode{},
+		&models.TwoFactorChallenge{},
+		&models.Setting{},
+		&models.Group{},
+		&models.GroupContent{},
 		&models.Lesson{},
 		&models.Test{},
+		&models.QuestionPool{},
 		&models.TestQuestion{},
+		&models.TestPoolRule{},
 		&models.TestAttempt{},
+		&models.TestAttemptAnswer{},
+		&models.TestRegradeLog{},
 		&models.TestGrade{},
 		&models.Practice{},
 		&models.PracticeSubmit{},
+		&models.PracticeSubmitComment{},
 		&models.PracticeGrade{},
 		&models.Report{},
+		&models.ReportGrade{},
+		&models.DeadlineExtension{},
 		&models.Fact{},
 		&models.Video{},
-	)
+	); err != nil {
+		return err
+	}
+
+	if err := migrateUserRoleConstraint(db); err != nil {
+		return err
+	}
+	if err := RunOnce(db, "practice_submit_versions", backfillPracticeSubmitVersions); err != nil {
+		return err
+	}
+	if err := RunOnce(db, "raw_scores", backfillRawScores); err != nil {
+		return err
+	}
+	return RunOnce(db, "grade_scales", backfillGradeScales)
+}
+
+// RunOnce выполняет разовую миграцию данных name, если она еще не выполнялась, и отмечает ее в настройках.
+// При ошибке отметка не ставится, и миграция повторяется при следующем запуске.
+func RunOnce(db *gorm.DB, name string, migrate func(db *gorm.DB) error) error {
+	key := models.SettingMigrationPrefix + name
+	var done int64
+	if err := db.Model(&models.Setting{}).Where("key = ?", key).Count(&done).Error; err != nil {
+		return err
+	}
+	if done > 0 {
+		return nil
+	}
+
+	if err := migrate(db); err != nil {
+		return fmt.Errorf("миграция %s: %w", name, err)
+	}
+	return db.Create(&models.Setting{Key: key, Value: time.Now().Format(time.RFC3339)}).Error
+}
+
+// migrateUserRoleConstraint пересоздает ограничение ролей пользователей, если в нем нет роли teacher:
+// AutoMigrate не обновляет существующие CHECK-ограничения
+func migrateUserRoleConstraint(db *gorm.DB) error {
+	var definition string
+	db.Raw("SELECT pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = 'chk_users_role'").Scan(&definition)
+	if strings.Contains(definition, models.RoleTeacher) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_role").Error; err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('student', 'teacher', 'admin'))").Error
+	})
+}
+
+// backfillPracticeSubmitVersions нумерует ранее сделанные отправки по порядку создания
+// в рамках студента и задания
+func backfillPracticeSubmitVersions(db *gorm.DB) error {
+	return db.Exec(`UPDATE practice_submits AS ps SET version = v.rn
+		FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, practice_id ORDER BY created_at, id) AS rn
+			FROM practice_submits WHERE deleted_at IS NULL) AS v
+		WHERE ps.id = v.id AND ps.version <> v.rn`).Error
+}
+
+// backfillRawScores заполняет баллы до штрафа для работ, сданных до появления штрафов за опоздание
+func backfillRawScores(db *gorm.DB) error {
+	if err := db.Exec("UPDATE test_attempts SET raw_score = score WHERE late_penalty = 0 AND raw_score <> score").Error; err != nil {
+		return err
+	}
+	return db.Exec("UPDATE practice_grades SET raw_grade = grade WHERE late_penalty = 0 AND raw_grade <> grade").Error
+}
+
+// backfillGradeScales задает шкалу оценкам, выставленным до появления поля scale.
+// Раньше шкала определялась по значению: до 5 - пятибалльная, больше - проценты.
+func backfillGradeScales(db *gorm.DB) error {
+	for _, table := range []string{"test_grades", "practice_grades"} {
+		if err := db.Exec("UPDATE " + table + " SET scale = CASE WHEN grade <= 5 THEN 'five' ELSE 'percent' END WHERE scale IS NULL OR scale = ''").Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
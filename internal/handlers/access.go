@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"geografi-cheb/backend/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// can проверяет, есть ли у текущего пользователя право
+func can(c *gin.Context, permission string) bool {
+	role, _ := c.Get("user_role")
+	roleName, _ := role.(string)
+	return models.RoleHasPermission(roleName, permission)
+}
+
+// isStaff проверяет, что текущий пользователь управляет содержимым (преподаватель или администратор)
+// и должен видеть полные данные, включая ключи ответов
+func isStaff(c *gin.Context) bool {
+	return can(c, models.PermManageContent)
+}
+
+// teacherStudentIDs возвращает подзапрос ID студентов из групп, которые ведет преподаватель
+func teacherStudentIDs(db *gorm.DB, teacherID interface{}) *gorm.DB {
+	return db.Table("group_members").
+		Select("group_members.user_id").
+		Joins("JOIN group_teachers ON group_teachers.group_id = group_members.group_id").
+		Joins(`JOIN "groups" ON "groups".id = group_members.group_id AND "groups".deleted_at IS NULL`).
+		Where("group_teachers.user_id = ?", teacherID)
+}
+
+// activeGroupContent возвращает подзапрос ID материалов типа contentType, назначенных существующим группам
+func activeGroupContent(db *gorm.DB, contentType string) *gorm.DB {
+	return db.Table("group_contents").
+		Select("group_contents.content_id").
+		Joins(`JOIN "groups" ON "groups".id = group_contents.group_id AND "groups".deleted_at IS NULL`).
+		Where("group_contents.content_type = ?", contentType)
+}
+
+// restrictedContent возвращает подзапрос ID материалов типа contentType, которые группам groupIDs
+// (список или подзапрос ID) видны только по собственному назначению. Назначение администратора
+// действует во всех группах, назначение преподавателя - только в группах этого преподавателя.
+func restrictedContent(db *gorm.DB, contentType string, groupIDs interface{}) *gorm.DB {
+	return activeGroupContent(db, contentType).
+		Where("group_contents.teacher_id IS NULL OR group_contents.teacher_id IN (?)",
+			db.Table("group_teachers").Select("user_id").Where("group_id IN (?)", groupIDs))
+}
+
+// whereVisibleToStudent оставляет материалы типа contentType (ID в колонке column), доступные студенту
+func whereVisibleToStudent(db, newDB *gorm.DB, column, contentType string, userID interface{}) *gorm.DB {
+	groupIDs := newDB.Table("group_members").Select("group_id").Where("user_id = ?", userID)
+	return db.Where(column+" NOT IN (?) OR "+column+" IN (?)",
+		restrictedContent(newDB, contentType, groupIDs),
+		activeGroupContent(newDB, contentType).
+			Joins("JOIN group_members ON group_members.group_id = group_contents.group_id").
+			Where("group_members.user_id = ?", userID).
+			Where("group_contents.publish_at IS NULL OR group_contents.publish_at <= ?", time.Now()))
+}
+
+// visibleContent ограничивает запрос материалами, доступными текущему пользователю.
+// Материал без назначений виден всем; назначенный группам - только их студентам
+// после даты публикации (см. restrictedContent). Тесты и практики скрытого урока
+// скрыты вместе с ним. Преподаватели и администраторы видят все.
+func visibleContent(c *gin.Context, contentType string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if isStaff(c) {
+			return db
+		}
+		userID, _ := c.Get("user_id")
+		newDB := db.Session(&gorm.Session{NewDB: true})
+		db = whereVisibleToStudent(db, newDB, "id", contentType, userID)
+		if contentType != models.ContentLesson {
+			db = whereVisibleToStudent(db, newDB, "lesson_id", models.ContentLesson, userID)
+		}
+		return db
+	}
+}
+
+// gradingScope ограничивает запрос работами, которые может видеть текущий пользователь:
+// администратор - все, преподаватель - студентов своих групп, студент - только свои.
+// column - колонка с ID студента.
+func gradingScope(c *gin.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		userID, _ := c.Get("user_id")
+		switch {
+		case can(c, models.PermGradeAll):
+			return db
+		case can(c, models.PermGrade):
+			return db.Where(column+" IN (?)", teacherStudentIDs(db.Session(&gorm.Session{NewDB: true}), userID))
+		default:
+			return db.Where(column+" = ?", userID)
+		}
+	}
+}
+
+// canGradeUser проверяет, может ли текущий пользователь проверять работы студента
+func (h *Handlers) canGradeUser(c *gin.Context, studentID uint) bool {
+	if can(c, models.PermGradeAll) {
+		return true
+	}
+	if !can(c, models.PermGrade) {
+		return false
+	}
+	var count int64
+	h.DB.Model(&models.User{}).Where("id = ? AND id IN (?)", studentID, teacherStudentIDs(h.DB, c.MustGet("user_id"))).Count(&count)
+	return count > 0
+}
+
+// ownerScope ограничивает запрос записями текущего пользователя (колонка user_id).
+// При allowStaff проверяющие видят записи в пределах gradingScope.
+func ownerScope(c *gin.Context, allowStaff bool) func(*gorm.DB) *gorm.DB {
+	if allowStaff {
+		return gradingScope(c, "user_id")
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		userID, _ := c.Get("user_id")
+		return db.Where("user_id = ?", userID)
+	}
+}
+
+// loadOwned загружает запись по ID из пути с проверкой владельца.
+// Чужая, несуществующая запись и неверный ID дают одинаковый ответ 404,
+// чтобы по ответу нельзя было узнать о существовании записи.
+func loadOwned[T any](c *gin.Context, query *gorm.DB, allowStaff bool, notFound string) (*T, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return nil, false
+	}
+
+	var record T
+	if err := query.Scopes(ownerScope(c, allowStaff)).First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return nil, false
+	}
+	return &record, true
+}
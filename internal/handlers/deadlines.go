@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validateDeadline проверяет порядок дат окна сдачи и размер штрафа
+func validateDeadline(d models.Deadline) error {
+	if d.LatePenalty < 0 || d.LatePenalty > 100 {
+		return errors.New("late_penalty должен быть от 0 до 100")
+	}
+	if d.OpensAt != nil && d.DueAt != nil && d.DueAt.Before(*d.OpensAt) {
+		return errors.New("due_at не может быть раньше opens_at")
+	}
+	if d.OpensAt != nil && d.ClosesAt != nil && d.ClosesAt.Before(*d.OpensAt) {
+		return errors.New("closes_at не может быть раньше opens_at")
+	}
+	if d.DueAt != nil && d.ClosesAt != nil && d.ClosesAt.Before(*d.DueAt) {
+		return errors.New("closes_at не может быть раньше due_at")
+	}
+	return nil
+}
+
+// effectiveDeadline возвращает окно сдачи задания для студента с учетом продления
+func effectiveDeadline(db *gorm.DB, assignmentType string, assignmentID uint, base models.Deadline, userID interface{}) models.Deadline {
+	var ext models.DeadlineExtension
+	if err := db.Where("assignment_type = ? AND assignment_id = ? AND user_id = ?", assignmentType, assignmentID, userID).First(&ext).Error; err != nil {
+		return base
+	}
+	return base.Effective(&ext)
+}
+
+// DeadlineExtensionRequest структура запроса продления срока
+type DeadlineExtensionRequest struct {
+	AssignmentType string     `json:"assignment_type" binding:"required"` // test или practice
+	AssignmentID   uint       `json:"assignment_id" binding:"required"`
+	UserID         uint       `json:"user_id" binding:"required"`
+	DueAt          *time.Time `json:"due_at"`
+	ClosesAt       *time.Time `json:"closes_at"`
+	Reason         string     `json:"reason"`
+}
+
+// GetDeadlineExtensions возвращает продления сроков с фильтрами assignment_type, assignment_id, user_id (только для админа)
+func (h *Handlers) GetDeadlineExtensions(c *gin.Context) {
+	query := h.DB.Preload("User").Scopes(gradingScope(c, "user_id"))
+	if assignmentType := c.Query("assignment_type"); assignmentType != "" {
+		query = query.Where("assignment_type = ?", assignmentType)
+	}
+	if assignmentID := c.Query("assignment_id"); assignmentID != "" {
+		query = query.Where("assignment_id = ?", assignmentID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var extensions []models.DeadlineExtension
+	query.Order("created_at DESC").Find(&extensions)
+	c.JSON(http.StatusOK, extensions)
+}
+
+// CreateDeadlineExtension создает или обновляет продление срока для студента (только для админа)
+func (h *Handlers) CreateDeadlineExtension(c *gin.Context) {
+	var req DeadlineExtensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DueAt == nil && req.ClosesAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите due_at или closes_at"})
+		return
+	}
+	var student models.User
+	if err := h.DB.Select("id").First(&student, req.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if !h.canGradeUser(c, student.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Студент не входит в ваши группы"})
+		return
+	}
+
+	// Проверяем задание и итоговое окно сдачи
+	var base models.Deadline
+	switch req.AssignmentType {
+	case models.AssignmentTest:
+		var test models.Test
+		if err := h.DB.First(&test, req.AssignmentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+			return
+		}
+		base = test.Deadline
+	case models.AssignmentPractice:
+		var practice models.Practice
+		if err := h.DB.First(&practice, req.AssignmentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Практическое задание не найдено"})
+			return
+		}
+		base = practice.Deadline
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "assignment_type должен быть 'test' или 'practice'"})
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+	ext := models.DeadlineExtension{
+		AssignmentType: req.AssignmentType,
+		AssignmentID:   req.AssignmentID,
+		UserID:         req.UserID,
+	}
+	h.DB.Unscoped().Where(&ext).First(&ext)
+	ext.DueAt = req.DueAt
+	ext.ClosesAt = req.ClosesAt
+	ext.Reason = req.Reason
+	ext.GrantedBy = adminID.(uint)
+	ext.DeletedAt = gorm.DeletedAt{}
+
+	if err := validateDeadline(base.Effective(&ext)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.DB.Unscoped().Save(&ext).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка сохранения продления"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ext)
+}
+
+// DeleteDeadlineExtension отменяет продление срока (только для админа)
+func (h *Handlers) DeleteDeadlineExtension(c *gin.Context) {
+	h.deleteScoped(c, &models.DeadlineExtension{}, "Продление не найдено", "Продление удалено")
+}
+
+// setPracticeGrade выставляет оценку практики с учетом штрафа за опоздание отправки:
+// указанной в оценке или последней версии
+func (h *Handlers) setPracticeGrade(grade *models.PracticeGrade, rawGrade float64) {
+	var submit models.PracticeSubmit
+	query := h.DB.Where("user_id = ? AND practice_id = ?", grade.UserID, grade.PracticeID)
+	if grade.SubmitID != 0 {
+		query = query.Where("id = ?", grade.SubmitID)
+	}
+
+	penalty := 0.0
+	if err := query.Order("version DESC").First(&submit).Error; err == nil {
+		penalty = submit.LatePenalty
+	}
+
+	grade.RawGrade = rawGrade
+	grade.LatePenalty = penalty
+	grade.Grade = models.ApplyLatePenalty(rawGrade, penalty)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// gradebookFilter параметры выборки журнала
+type gradebookFilter struct {
+	Group *models.Group // nil - без ограничения группой
+	From  *time.Time    // Начало периода по сроку сдачи (или дате создания) задания
+	To    *time.Time    // Конец периода, не включительно
+}
+
+// parseGradebookFilter разбирает group_id и период журнала
+func (h *Handlers) parseGradebookFilter(c *gin.Context) (gradebookFilter, bool) {
+	var filter gradebookFilter
+
+	if groupParam := c.Query("group_id"); groupParam != "" {
+		groupID, err := strconv.ParseUint(groupParam, 10, 32)
+		var group models.Group
+		if err != nil || h.DB.First(&group, groupID).Error != nil || !h.canManageGroup(c, group.ID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+			return filter, false
+		}
+		filter.Group = &group
+	}
+
+	return filter, parseGradebookPeriod(c, &filter)
+}
+
+// parseGradebookPeriod разбирает from и to (YYYY-MM-DD, to включительно)
+func parseGradebookPeriod(c *gin.Context, filter *gradebookFilter) bool {
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+		shift  time.Duration
+	}{
+		{"from", &filter.From, 0},
+		{"to", &filter.To, 24 * time.Hour},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param.name + " должен быть датой в формате YYYY-MM-DD"})
+			return false
+		}
+		date = date.Add(param.shift)
+		*param.target = &date
+	}
+	return true
+}
+
+// assignmentScope ограничивает задания типа contentType группой и периодом фильтра
+func assignmentScope(filter gradebookFilter, contentType string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Group != nil {
+			newDB := db.Session(&gorm.Session{NewDB: true})
+			groupIDs := []uint{filter.Group.ID}
+			db = db.Where("id NOT IN (?) OR id IN (?)",
+				restrictedContent(newDB, contentType, groupIDs),
+				newDB.Table("group_contents").Select("content_id").
+					Where("content_type = ? AND group_id = ?", contentType, filter.Group.ID)).
+				Where("lesson_id NOT IN (?) OR lesson_id IN (?)",
+					restrictedContent(newDB, models.ContentLesson, groupIDs),
+					newDB.Table("group_contents").Select("content_id").
+						Where("content_type = ? AND group_id = ?", models.ContentLesson, filter.Group.ID))
+		}
+		if filter.From != nil {
+			db = db.Where("COALESCE(due_at, created_at) >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			db = db.Where("COALESCE(due_at, created_at) < ?", *filter.To)
+		}
+		return db
+	}
+}
+
+// loadGradebook собирает журнал для студентов, выбранных запросом students
+func (h *Handlers) loadGradebook(students *gorm.DB, testScope, practiceScope func(*gorm.DB) *gorm.DB, weights models.GradeWeights) pkg.Gradebook {
+	var data pkg.GradebookData
+	students.Where("role = ?", models.RoleStudent).Find(&data.Students)
+	h.DB.Scopes(testScope).Order("id ASC").Find(&data.Tests)
+	h.DB.Scopes(practiceScope).Order("id ASC").Find(&data.Practices)
+
+	userIDs := make([]uint, len(data.Students))
+	for i, student := range data.Students {
+		userIDs[i] = student.ID
+	}
+	testIDs := make([]uint, len(data.Tests))
+	for i, test := range data.Tests {
+		testIDs[i] = test.ID
+	}
+	practiceIDs := make([]uint, len(data.Practices))
+	for i, practice := range data.Practices {
+		practiceIDs[i] = practice.ID
+	}
+
+	if len(userIDs) > 0 && len(testIDs) > 0 {
+		h.DB.Where("user_id IN ? AND test_id IN ?", userIDs, testIDs).Find(&data.TestGrades)
+		h.DB.Where("user_id IN ? AND test_id IN ? AND status <> ?", userIDs, testIDs, models.AttemptInProgress).
+			Order("created_at ASC").Find(&data.Attempts)
+	}
+	if len(userIDs) > 0 && len(practiceIDs) > 0 {
+		h.DB.Where("user_id IN ? AND practice_id IN ?", userIDs, practiceIDs).Find(&data.PracticeGrades)
+	}
+
+	return pkg.BuildGradebook(data, weights)
+}
+
+// buildGradebook собирает журнал по фильтру запроса: студенты группы или,
+// без группы, все студенты в пределах прав проверяющего
+func (h *Handlers) buildGradebook(c *gin.Context) (pkg.Gradebook, bool) {
+	filter, ok := h.parseGradebookFilter(c)
+	if !ok {
+		return pkg.Gradebook{}, false
+	}
+
+	students := h.DB.Model(&models.User{}).Scopes(gradingScope(c, "id"))
+	weights := models.DefaultGradeWeights()
+	if filter.Group != nil {
+		students = students.Where("id IN (?)", h.DB.Table("group_members").Select("user_id").Where("group_id = ?", filter.Group.ID))
+		weights = filter.Group.GradeWeights
+	}
+
+	return h.loadGradebook(students,
+		assignmentScope(filter, models.ContentTest),
+		assignmentScope(filter, models.ContentPractice), weights), true
+}
+
+// GetGradebook возвращает журнал оценок студенты × задания с фильтрами group_id, from, to
+func (h *Handlers) GetGradebook(c *gin.Context) {
+	book, ok := h.buildGradebook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, book)
+}
+
+// ExportGradebook выгружает журнал в CSV или XLSX (format=csv|xlsx, по умолчанию csv)
+// с фильтрами group_id, from, to; scale=mark выгружает пятибалльные оценки вместо процентов
+func (h *Handlers) ExportGradebook(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format должен быть 'csv' или 'xlsx'"})
+		return
+	}
+
+	book, ok := h.buildGradebook(c)
+	if !ok {
+		return
+	}
+	table := pkg.GradebookTable(book, c.Query("scale") == "mark")
+
+	filename := "gradebook"
+	if groupID := c.Query("group_id"); groupID != "" {
+		filename += "-group-" + groupID
+	}
+	for _, param := range []string{"from", "to"} {
+		if value := c.Query(param); value != "" {
+			filename += "-" + param + "-" + value
+		}
+	}
+	filename += "." + format
+
+	if format == "xlsx" {
+		// Книга собирается в памяти, чтобы при ошибке ответить 500, а не отдать обрезанный файл
+		var file bytes.Buffer
+		if err := pkg.WriteXLSX(&file, "Журнал", table); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка формирования XLSX"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", file.Bytes())
+		return
+	}
+	writeGradebookCSV(c, filename, table)
+}
+
+// writeGradebookCSV отдает таблицу журнала в CSV
+func writeGradebookCSV(c *gin.Context, filename string, table [][]interface{}) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Status(http.StatusOK)
+
+	// BOM, чтобы Excel распознал UTF-8
+	c.Writer.Write([]byte("\xEF\xBB\xBF"))
+	w := csv.NewWriter(c.Writer)
+	for _, row := range table {
+		record := make([]string, len(row))
+		for i, value := range row {
+			switch v := value.(type) {
+			case nil:
+				record[i] = ""
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case string:
+				// Имена студентов, группы и названия заданий вводят пользователи
+				record[i] = csvText(v)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// GetMyGradebook возвращает строку журнала текущего студента по доступным ему заданиям
+func (h *Handlers) GetMyGradebook(c *gin.Context) {
+	var filter gradebookFilter
+	if !parseGradebookPeriod(c, &filter) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	// Веса берутся из группы студента
+	weights := models.DefaultGradeWeights()
+	var group models.Group
+	if err := h.DB.Where("id IN (?)", h.DB.Table("group_members").Select("group_id").Where("user_id = ?", userID)).
+		Order("id ASC").First(&group).Error; err == nil {
+		weights = group.GradeWeights
+	}
+
+	testScope := func(db *gorm.DB) *gorm.DB {
+		return assignmentScope(filter, models.ContentTest)(visibleContent(c, models.ContentTest)(db))
+	}
+	practiceScope := func(db *gorm.DB) *gorm.DB {
+		return assignmentScope(filter, models.ContentPractice)(visibleContent(c, models.ContentPractice)(db))
+	}
+	book := h.loadGradebook(h.DB.Where("id = ?", userID), testScope, practiceScope, weights)
+	c.JSON(http.StatusOK, book)
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// joinCodeLength - длина кода вступления в группу
+const joinCodeLength = 8
+
+// GroupRequest структура запроса создания/обновления группы
+type GroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	models.GradeWeights
+}
+
+// validateGradeWeights проверяет веса категорий журнала
+func validateGradeWeights(w models.GradeWeights) string {
+	if w.TestWeight < 0 || w.PracticeWeight < 0 {
+		return "Веса категорий не могут быть отрицательными"
+	}
+	if w.TestWeight == 0 && w.PracticeWeight == 0 {
+		return "Хотя бы одна категория должна иметь положительный вес"
+	}
+	return ""
+}
+
+// GroupUsersRequest структура запроса со списком пользователей группы
+type GroupUsersRequest struct {
+	UserIDs []uint `json:"user_ids"`
+}
+
+// newJoinCode генерирует код вступления в группу
+func newJoinCode() (*string, error) {
+	code, err := pkg.RandomCode(joinCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// canManageGroup проверяет, может ли текущий пользователь управлять материалами группы:
+// администратор - любой, преподаватель - только своей
+func (h *Handlers) canManageGroup(c *gin.Context, groupID uint) bool {
+	if can(c, models.PermManageGroups) {
+		return true
+	}
+	var count int64
+	h.DB.Table("group_teachers").Where("group_id = ? AND user_id = ?", groupID, c.MustGet("user_id")).Count(&count)
+	return count > 0
+}
+
+// GetGroups возвращает все группы с преподавателями (только для админа)
+func (h *Handlers) GetGroups(c *gin.Context) {
+	var groups []models.Group
+	h.DB.Preload("Teachers").Order("name ASC").Find(&groups)
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetGroup возвращает группу с преподавателями и студентами (только для админа)
+func (h *Handlers) GetGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID группы"})
+		return
+	}
+
+	var group models.Group
+	if err := h.DB.Preload("Teachers").Preload("Members").First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// CreateGroup создает группу (только для админа)
+func (h *Handlers) CreateGroup(c *gin.Context) {
+	req := GroupRequest{GradeWeights: models.DefaultGradeWeights()}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateGradeWeights(req.GradeWeights); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	joinCode, err := newJoinCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации кода группы"})
+		return
+	}
+
+	group := models.Group{Name: req.Name, Description: req.Description, JoinCode: joinCode, GradeWeights: req.GradeWeights}
+	if err := h.DB.Create(&group).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания группы"})
+		return
+	}
+	// Нулевой вес при вставке заменяется значением по умолчанию из БД, сохраняем переданный
+	h.DB.Model(&group).Updates(map[string]interface{}{"test_weight": req.TestWeight, "practice_weight": req.PracticeWeight})
+	group.GradeWeights = req.GradeWeights
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup обновляет группу (только для админа)
+func (h *Handlers) UpdateGroup(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var group models.Group
+	if err := h.DB.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return
+	}
+
+	// Не переданные веса сохраняют текущие значения
+	req := GroupRequest{GradeWeights: group.GradeWeights}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateGradeWeights(req.GradeWeights); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	group.Name = req.Name
+	group.Description = req.Description
+	group.GradeWeights = req.GradeWeights
+	if err := h.DB.Save(&group).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка обновления группы"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup удаляет группу (только для админа)
+func (h *Handlers) DeleteGroup(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	h.DB.Delete(&models.Group{}, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Группа удалена"})
+}
+
+// RegenerateJoinCode выдает группе новый код вступления, старый перестает действовать (только для админа)
+func (h *Handlers) RegenerateJoinCode(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var group models.Group
+	if err := h.DB.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return
+	}
+
+	joinCode, err := newJoinCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации кода группы"})
+		return
+	}
+	if err := h.DB.Model(&group).Update("join_code", joinCode).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления кода группы"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// SetGroupTeachers заменяет список преподавателей группы (только для админа)
+func (h *Handlers) SetGroupTeachers(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var group models.Group
+	if err := h.DB.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return
+	}
+
+	var req GroupUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	teachers := []models.User{}
+	if len(req.UserIDs) > 0 {
+		h.DB.Where("id IN ? AND role = ?", req.UserIDs, models.RoleTeacher).Find(&teachers)
+		if len(teachers) != len(req.UserIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Преподавателем группы может быть только пользователь с ролью teacher"})
+			return
+		}
+	}
+
+	if err := h.DB.Model(&group).Association("Teachers").Replace(teachers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка назначения преподавателей"})
+		return
+	}
+
+	h.DB.Preload("Teachers").First(&group, group.ID)
+	c.JSON(http.StatusOK, group)
+}
+
+// AddGroupMembers зачисляет студентов в группу (только для админа)
+func (h *Handlers) AddGroupMembers(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var group models.Group
+	if err := h.DB.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return
+	}
+
+	var req GroupUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UserIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите user_ids"})
+		return
+	}
+
+	var students []models.User
+	h.DB.Where("id IN ? AND role = ?", req.UserIDs, models.RoleStudent).Find(&students)
+	if len(students) != len(req.UserIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "В группу можно зачислить только студентов"})
+		return
+	}
+
+	if err := h.DB.Model(&group).Association("Members").Append(students); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка зачисления в группу"})
+		return
+	}
+
+	h.DB.Preload("Teachers").Preload("Members").First(&group, group.ID)
+	c.JSON(http.StatusOK, group)
+}
+
+// RemoveGroupMember отчисляет студента из группы (только для админа)
+func (h *Handlers) RemoveGroupMember(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := strconv.ParseUint(c.Param("userId"), 10, 32)
+
+	var group models.Group
+	if err := h.DB.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return
+	}
+
+	if err := h.DB.Model(&group).Association("Members").Delete(&models.User{ID: uint(userID)}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отчисления из группы"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Студент отчислен из группы"})
+}
+
+// GetMyGroups возвращает группы, в которых учится или которые ведет текущий пользователь
+func (h *Handlers) GetMyGroups(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	// Студент видит только опубликованные материалы
+	publishedContent := func(db *gorm.DB) *gorm.DB {
+		if isStaff(c) {
+			return db
+		}
+		return db.Where("publish_at IS NULL OR publish_at <= ?", time.Now())
+	}
+
+	var groups []models.Group
+	h.DB.Preload("Teachers").
+		Preload("Content", publishedContent).
+		Where("id IN (?) OR id IN (?)",
+			h.DB.Table("group_members").Select("group_id").Where("user_id = ?", userID),
+			h.DB.Table("group_teachers").Select("group_id").Where("user_id = ?", userID)).
+		Order("name ASC").Find(&groups)
+
+	// Код вступления видят только преподаватели
+	if !can(c, models.PermGrade) {
+		for i := range groups {
+			groups[i].JoinCode = nil
+		}
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// JoinGroupRequest структура запроса вступления в группу
+type JoinGroupRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// JoinGroup зачисляет текущего студента в группу по коду
+func (h *Handlers) JoinGroup(c *gin.Context) {
+	var req JoinGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if user.Role != models.RoleStudent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Вступить в группу по коду может только студент"})
+		return
+	}
+
+	var group models.Group
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if err := h.DB.Where("join_code = ?", code).First(&group).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа с таким кодом не найдена"})
+		return
+	}
+
+	if err := h.DB.Model(&group).Association("Members").Append(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка вступления в группу"})
+		return
+	}
+
+	group.JoinCode = nil
+	c.JSON(http.StatusOK, group)
+}
+
+// GroupContentRequest структура запроса назначения материала группе
+type GroupContentRequest struct {
+	ContentType string     `json:"content_type" binding:"required"` // lesson, test или practice
+	ContentID   uint       `json:"content_id" binding:"required"`
+	PublishAt   *time.Time `json:"publish_at"` // nil - опубликовать сразу
+}
+
+// contentExists проверяет существование назначаемого материала
+func (h *Handlers) contentExists(contentType string, contentID uint) bool {
+	var model interface{}
+	switch contentType {
+	case models.ContentLesson:
+		model = &models.Lesson{}
+	case models.ContentTest:
+		model = &models.Test{}
+	case models.ContentPractice:
+		model = &models.Practice{}
+	default:
+		return false
+	}
+	var count int64
+	h.DB.Model(model).Where("id = ?", contentID).Count(&count)
+	return count > 0
+}
+
+// loadManagedGroup загружает группу из пути, которой может управлять текущий пользователь
+func (h *Handlers) loadManagedGroup(c *gin.Context) (*models.Group, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return nil, false
+	}
+
+	var group models.Group
+	if err := h.DB.First(&group, id).Error; err != nil || !h.canManageGroup(c, group.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Группа не найдена"})
+		return nil, false
+	}
+	return &group, true
+}
+
+// GetGroupContent возвращает материалы, назначенные группе (фильтр content_type)
+func (h *Handlers) GetGroupContent(c *gin.Context) {
+	group, ok := h.loadManagedGroup(c)
+	if !ok {
+		return
+	}
+
+	query := h.DB.Where("group_id = ?", group.ID)
+	if contentType := c.Query("content_type"); contentType != "" {
+		query = query.Where("content_type = ?", contentType)
+	}
+
+	var content []models.GroupContent
+	query.Order("content_type ASC, content_id ASC").Find(&content)
+	c.JSON(http.StatusOK, content)
+}
+
+// AssignGroupContent назначает материал группе или меняет дату публикации
+func (h *Handlers) AssignGroupContent(c *gin.Context) {
+	group, ok := h.loadManagedGroup(c)
+	if !ok {
+		return
+	}
+
+	var req GroupContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidContentType(req.ContentType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content_type должен быть 'lesson', 'test' или 'practice'"})
+		return
+	}
+	if !h.contentExists(req.ContentType, req.ContentID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Материал не найден"})
+		return
+	}
+
+	content := models.GroupContent{
+		GroupID:     group.ID,
+		ContentType: req.ContentType,
+		ContentID:   req.ContentID,
+	}
+	// Назначение преподавателя действует только в его группах
+	var teacherID *uint
+	if !can(c, models.PermManageGroups) {
+		userID := c.MustGet("user_id").(uint)
+		teacherID = &userID
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		tx.Where(&content).First(&content)
+		content.PublishAt = req.PublishAt
+		content.TeacherID = teacherID
+		return tx.Save(&content).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка назначения материала"})
+		return
+	}
+
+	c.JSON(http.StatusOK, content)
+}
+
+// RemoveGroupContent снимает назначение материала с группы
+func (h *Handlers) RemoveGroupContent(c *gin.Context) {
+	group, ok := h.loadManagedGroup(c)
+	if !ok {
+		return
+	}
+
+	contentID, _ := strconv.ParseUint(c.Param("contentId"), 10, 32)
+	h.DB.Where("group_id = ?", group.ID).Delete(&models.GroupContent{}, contentID)
+	c.JSON(http.StatusOK, gin.H{"message": "Назначение снято"})
+}
@@ -5,27 +5,42 @@ import (
 	"geografi-cheb/backend/models"
 	"geografi-cheb/backend/pkg"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Handlers содержит все обработчики запросов1
 type Handlers struct {
-	DB     *gorm.DB
-	Config *config.Config
+	DB      *gorm.DB
+	Config  *config.Config
+	Mailer  pkg.Mailer
+	Users   *pkg.UserCache // Пользователи для проверки запросов; сбрасывать после изменения роли, статуса и токенов
+	Limiter *pkg.LoginLimiter
 }
 
 // NewHandlers создает новый экземпляр обработчиков
-func NewHandlers(db *gorm.DB, cfg *config.Config) *Handlers {
+func NewHandlers(db *gorm.DB, cfg *config.Config) (*Handlers, error) {
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Устанавливаем JWT секрет
 	pkg.SetJWTSecret(cfg.JWTSecret)
+	pkg.SetAccessTokenTTL(cfg.AccessTokenTTL)
 	return &Handlers{
-		DB:     db,
-		Config: cfg,
-	}
+		DB:      db,
+		Config:  cfg,
+		Mailer:  mailer,
+		Users:   pkg.NewUserCache(db, cfg.AuthCacheTTL),
+		Limiter: newLoginLimiter(db, cfg),
+	}, nil
 }
 
 // RegisterRequest структура запроса регистрации
@@ -58,10 +73,11 @@ func (h *Handlers) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.Email = pkg.NormalizeEmail(req.Email)
 
-	// Проверяем, существует ли пользователь
+	// Проверяем, существует ли пользователь (в том числе удаленный: email уникален и среди них)
 	var existingUser models.User
-	if err := h.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := h.DB.Unscoped().Where("LOWER(email) = ?", req.Email).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Пользователь с таким email уже существует"})
 		return
 	}
@@ -86,8 +102,8 @@ func (h *Handlers) Register(c *gin.Context) {
 		return
 	}
 
-	// Генерируем токен для автоматического входа после регистрации
-	token, err := pkg.GenerateToken(user.ID, user.Email, user.Role)
+	// Открываем сессию для автоматического входа после регистрации
+	tokens, err := h.startSession(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
 		return
@@ -95,7 +111,9 @@ func (h *Handlers) Register(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Пользователь успешно зарегистрирован",
-		"token": token,
+		"token": tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in": tokens.ExpiresIn,
 		"user": gin.H{
 			"id":    user.ID,
 			"name":  user.Name,
@@ -123,35 +141,42 @@ func (h *Handlers) Login(c *gin.Context) {
 		return
 	}
 
+	req.Email = pkg.NormalizeEmail(req.Email)
+
+	// Попытка учитывается до проверки пароля, чтобы параллельные запросы не обходили ограничение
+	attempt, ok := h.reserveLoginAttempt(c, req.Email)
+	if !ok {
+		return
+	}
+
 	// Ищем пользователя
 	var user models.User
-	if err := h.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := h.DB.Where("LOWER(email) = ?", req.Email).First(&user).Error; err != nil {
+		h.recordLoginFailure(c, req.Email, nil, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный email или пароль"})
 		return
 	}
 
 	// Проверяем пароль
 	if !pkg.CheckPassword(req.Password, user.Password) {
+		h.recordLoginFailure(c, req.Email, &user, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный email или пароль"})
 		return
 	}
+	h.releaseLoginAttempt(c, req.Email)
 
-	// Генерируем токен
-	token, err := pkg.GenerateToken(user.ID, user.Email, user.Role)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+	if user.IsSuspended() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Учетная запись заблокирована", "code": "account_suspended"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
-		},
-	})
+	// С включенной 2FA вход завершается кодом на втором шаге
+	if user.TwoFactorEnabled {
+		h.startTwoFactorChallenge(c, &user)
+		return
+	}
+
+	h.completeLogin(c, &user)
 }
 
 // GetCurrentUser возвращает текущего авторизованного пользователя
@@ -177,7 +202,7 @@ func (h *Handlers) GetCurrentUser(c *gin.Context) {
 
 // GetUser возвращает пользователя по ID
 // @Summary Получить пользователя
-// @Description Возвращает информацию о пользователе по ID
+// @Description Возвращает информацию о пользователе по ID: себя, студентов своих групп (преподавателю) или любого (админу)
 // @Tags users
 // @Security BearerAuth
 // @Produce json
@@ -191,7 +216,13 @@ func (h *Handlers) GetUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID пользователя"})
 		return
 	}
-	
+
+	// Чужой профиль отвечает так же, как несуществующий
+	userID, _ := c.Get("user_id")
+	if uint(id) != userID.(uint) && !h.canGradeUser(c, uint(id)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
 	var user models.User
 	if err := h.DB.First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
@@ -211,7 +242,7 @@ func (h *Handlers) GetUser(c *gin.Context) {
 // @Router /lessons [get]
 func (h *Handlers) GetLessons(c *gin.Context) {
 	var lessons []models.Lesson
-	h.DB.Order("number ASC").Find(&lessons)
+	h.DB.Scopes(visibleContent(c, models.ContentLesson)).Order("number ASC").Find(&lessons)
 	c.JSON(http.StatusOK, lessons)
 }
 
@@ -232,12 +263,25 @@ func (h *Handlers) GetLesson(c *gin.Context) {
 		return
 	}
 	
+	// Студент видит только свои доклады, преподаватель - доклады студентов своих групп
+	userID, _ := c.Get("user_id")
+
 	var lesson models.Lesson
-	if err := h.DB.Preload("Reports").Preload("Practices").Preload("Videos").Preload("Tests").Preload("Tests.Questions").First(&lesson, id).Error; err != nil {
+	if err := h.DB.Preload("Reports", gradingScope(c, "user_id")).
+		Preload("Practices", visibleContent(c, models.ContentPractice)).
+		Preload("Videos").
+		Preload("Tests", visibleContent(c, models.ContentTest)).Preload("Tests.Questions").Preload("Tests.PoolRules").
+		Scopes(visibleContent(c, models.ContentLesson)).First(&lesson, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Урок не найден"})
 		return
 	}
 
+	// Студентам ключи ответов не отдаем
+	if !isStaff(c) {
+		c.JSON(http.StatusOK, models.NewLessonStudentView(lesson, h.revealedTestIDs(userID, lesson.Tests)))
+		return
+	}
+
 	c.JSON(http.StatusOK, lesson)
 }
 
@@ -315,10 +359,61 @@ func (h *Handlers) DeleteLesson(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Урок удален"})
 }
 
+// revealedTestIDs возвращает ID тестов, по которым студенту можно показать правильные ответы:
+// есть проверенная попытка, а новых попыток уже не будет - они исчерпаны или сдача закрыта.
+// Иначе ключ, увиденный после первой попытки, позволил бы пересдать тест на полный балл.
+func (h *Handlers) revealedTestIDs(userID interface{}, tests []models.Test) map[uint]bool {
+	var counts []struct {
+		TestID   uint
+		Total    int
+		Finished int
+	}
+	h.DB.Model(&models.TestAttempt{}).
+		Select("test_id, COUNT(*) AS total, SUM(CASE WHEN status <> ? THEN 1 ELSE 0 END) AS finished", models.AttemptInProgress).
+		Where("user_id = ?", userID).Group("test_id").Scan(&counts)
+
+	total := make(map[uint]int, len(counts))
+	finished := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		total[count.TestID] = count.Total
+		finished[count.TestID] = count.Finished
+	}
+
+	now := time.Now()
+	result := make(map[uint]bool)
+	for i := range tests {
+		test := &tests[i]
+		if finished[test.ID] == 0 {
+			continue
+		}
+		if limit := test.AttemptLimit(); limit > 0 && total[test.ID] >= limit {
+			result[test.ID] = true
+			continue
+		}
+		window := effectiveDeadline(h.DB, models.AssignmentTest, test.ID, test.Deadline, userID)
+		if window.ClosesAt != nil && now.After(*window.ClosesAt) {
+			result[test.ID] = true
+		}
+	}
+	return result
+}
+
 // GetTests возвращает список тестов
 func (h *Handlers) GetTests(c *gin.Context) {
 	var tests []models.Test
-	h.DB.Preload("Lesson").Preload("Questions").Find(&tests)
+	h.DB.Preload("Lesson").Preload("Questions").Preload("PoolRules").Scopes(visibleContent(c, models.ContentTest)).Find(&tests)
+
+	if !isStaff(c) {
+		userID, _ := c.Get("user_id")
+		revealed := h.revealedTestIDs(userID, tests)
+		views := make([]models.TestStudentView, 0, len(tests))
+		for _, test := range tests {
+			views = append(views, models.NewTestStudentView(test, revealed[test.ID]))
+		}
+		c.JSON(http.StatusOK, views)
+		return
+	}
+
 	c.JSON(http.StatusOK, tests)
 }
 
@@ -331,11 +426,19 @@ func (h *Handlers) GetTest(c *gin.Context) {
 	}
 	
 	var test models.Test
-	if err := h.DB.Preload("Lesson").Preload("Questions").First(&test, id).Error; err != nil {
+	if err := h.DB.Preload("Lesson").Preload("Questions").Preload("PoolRules").Scopes(visibleContent(c, models.ContentTest)).First(&test, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
 		return
 	}
 
+	// Студент видит ответы, только если тест это разрешает и пересдать его уже нельзя
+	if !isStaff(c) {
+		userID, _ := c.Get("user_id")
+		revealed := h.revealedTestIDs(userID, []models.Test{test})
+		c.JSON(http.StatusOK, models.NewTestStudentView(test, revealed[test.ID]))
+		return
+	}
+
 	c.JSON(http.StatusOK, test)
 }
 
@@ -345,17 +448,147 @@ type CreateTestRequest struct {
 	Title       string                    `json:"title" binding:"required"`
 	Description string                    `json:"description"`
 	Type        string                    `json:"type"` // single или multiple
-	Questions   []CreateTestQuestionRequest `json:"questions" binding:"required,min=1"`
+	ScoringMode string                    `json:"scoring_mode"` // all_or_nothing или partial
+	ShowCorrectAnswers bool                `json:"show_correct_answers"`
+	TimeLimit   int                       `json:"time_limit" binding:"min=0"` // Минуты, 0 - без ограничения
+	AllowRetake bool                      `json:"allow_retake"`
+	MaxAttempts int                       `json:"max_attempts" binding:"min=0"`
+	RetakeCooldown int                    `json:"retake_cooldown" binding:"min=0"` // Минуты
+	ScorePolicy string                    `json:"score_policy"` // best, last, average, first
+	ShuffleQuestions bool                 `json:"shuffle_questions"`
+	ShuffleOptions bool                   `json:"shuffle_options"`
+	Questions   []CreateTestQuestionRequest `json:"questions"`
+	PoolRules   []TestPoolRuleRequest       `json:"pool_rules"` // Случайная выборка вопросов из банков
+	models.Deadline                         // opens_at, due_at, closes_at, late_penalty
+}
+
+// TestPoolRuleRequest структура правила выборки вопросов из банка
+type TestPoolRuleRequest struct {
+	PoolID uint `json:"pool_id" binding:"required"`
+	Count  int  `json:"count" binding:"required,min=1"`
+}
+
+// buildPoolRules проверяет правила выборки: банк существует и в нем достаточно вопросов.
+// Правила одного банка объединяются, и с его размером сравнивается общее число вопросов.
+func (h *Handlers) buildPoolRules(rules []TestPoolRuleRequest) ([]models.TestPoolRule, error) {
+	result := make([]models.TestPoolRule, 0, len(rules))
+	index := make(map[uint]int, len(rules))
+	for _, rule := range rules {
+		if i, ok := index[rule.PoolID]; ok {
+			result[i].Count += rule.Count
+			continue
+		}
+		index[rule.PoolID] = len(result)
+		result = append(result, models.TestPoolRule{PoolID: rule.PoolID, Count: rule.Count})
+	}
+
+	for _, rule := range result {
+		var pool models.QuestionPool
+		if err := h.DB.First(&pool, rule.PoolID).Error; err != nil {
+			return nil, errors.New("Банк вопросов " + strconv.Itoa(int(rule.PoolID)) + " не найден")
+		}
+
+		var available int64
+		h.DB.Model(&models.TestQuestion{}).Where("pool_id = ?", rule.PoolID).Count(&available)
+		if int64(rule.Count) > available {
+			return nil, errors.New("Из банка \"" + pool.Title + "\" запрошено " + strconv.Itoa(rule.Count) +
+				" вопросов, а в нем только " + strconv.Itoa(int(available)))
+		}
+	}
+	return result, nil
 }
 
 // CreateTestQuestionRequest структура запроса создания вопроса
 type CreateTestQuestionRequest struct {
+	ID           uint     `json:"id"` // ID существующего вопроса при обновлении теста; без ID создается новый вопрос
+	Type         string   `json:"type"` // single, multiple, numeric, text, matching, ordering; по умолчанию - тип теста
 	Question     string   `json:"question" binding:"required"`
-	Options      []string `json:"options" binding:"required,min=2"`
-	CorrectAnswer int     `json:"correct_answer" binding:"required"`
+	Options      []string `json:"options"` // Варианты ответа (для matching - левый столбец)
+	MatchOptions []string `json:"match_options"` // Правый столбец для matching
+	CorrectAnswer *int    `json:"correct_answer"`  // Для single
+	CorrectAnswers []int  `json:"correct_answers"` // Для multiple
+	AnswerKey    json.RawMessage `json:"answer_key"` // Для numeric, text, matching, ordering, map
 	Order        int      `json:"order"`
 }
 
+// buildTestQuestion проверяет запрос и собирает вопрос теста или банка.
+// index - позиция вопроса в запросе, используется как порядок по умолчанию.
+// Связь с тестом или банком устанавливает вызывающий код.
+func buildTestQuestion(testType string, index int, qReq CreateTestQuestionRequest) (models.TestQuestion, error) {
+	questionType := qReq.Type
+	if questionType == "" {
+		questionType = testType
+	}
+	if questionType == "" {
+		questionType = models.QuestionSingle
+	}
+
+	grader, ok := pkg.GetQuestionGrader(questionType)
+	if !ok {
+		return models.TestQuestion{}, errors.New("Вопрос " + strconv.Itoa(index+1) + ": неизвестный тип вопроса '" + questionType + "'")
+	}
+
+	// Сериализуем варианты ответов в JSON
+	options := qReq.Options
+	if options == nil {
+		options = []string{}
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return models.TestQuestion{}, errors.New("Ошибка сериализации вариантов ответов")
+	}
+
+	question := models.TestQuestion{
+		Type:     questionType,
+		Question: qReq.Question,
+		Options:  string(optionsJSON),
+		Order:    qReq.Order,
+	}
+
+	switch questionType {
+	case models.QuestionSingle, models.QuestionMultiple:
+		correct := qReq.CorrectAnswers
+		if len(correct) == 0 && qReq.CorrectAnswer != nil {
+			correct = []int{*qReq.CorrectAnswer}
+		}
+		if len(correct) == 0 {
+			return models.TestQuestion{}, errors.New("Не указан правильный ответ для вопроса " + strconv.Itoa(index+1))
+		}
+		if questionType == models.QuestionSingle && len(correct) > 1 {
+			return models.TestQuestion{}, errors.New("Вопрос " + strconv.Itoa(index+1) + ": у вопроса с одним ответом может быть только один правильный вариант")
+		}
+		question.CorrectAnswer = correct[0]
+		if questionType == models.QuestionMultiple {
+			correctJSON, _ := json.Marshal(correct)
+			question.CorrectAnswers = string(correctJSON)
+		}
+	default:
+		if len(qReq.AnswerKey) == 0 {
+			return models.TestQuestion{}, errors.New("Не указан ключ ответа для вопроса " + strconv.Itoa(index+1))
+		}
+		question.AnswerKey = string(qReq.AnswerKey)
+	}
+
+	if len(qReq.MatchOptions) > 0 {
+		matchJSON, err := json.Marshal(qReq.MatchOptions)
+		if err != nil {
+			return models.TestQuestion{}, errors.New("Ошибка сериализации вариантов ответов")
+		}
+		question.MatchOptions = string(matchJSON)
+	}
+
+	if err := grader.Validate(&question); err != nil {
+		return models.TestQuestion{}, errors.New("Вопрос " + strconv.Itoa(index+1) + ": " + err.Error())
+	}
+
+	// Если порядок не указан, используем индекс
+	if question.Order == 0 {
+		question.Order = index + 1
+	}
+
+	return question, nil
+}
+
 // CreateTest создает новый тест (только для админа)
 func (h *Handlers) CreateTest(c *gin.Context) {
 	var req CreateTestRequest
@@ -372,6 +605,45 @@ func (h *Handlers) CreateTest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "type должен быть 'single' или 'multiple'"})
 		return
 	}
+	if req.ScoringMode == "" {
+		req.ScoringMode = pkg.ScoringAllOrNothing
+	}
+	if !pkg.IsValidScoringMode(req.ScoringMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scoring_mode должен быть 'all_or_nothing' или 'partial'"})
+		return
+	}
+	if req.ScorePolicy == "" {
+		req.ScorePolicy = models.ScorePolicyBest
+	}
+	if !models.IsValidScorePolicy(req.ScorePolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "score_policy должен быть 'best', 'last', 'average' или 'first'"})
+		return
+	}
+	if err := validateDeadline(req.Deadline); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Проверяем вопросы до создания теста
+	questions := make([]models.TestQuestion, 0, len(req.Questions))
+	for i, qReq := range req.Questions {
+		question, err := buildTestQuestion(req.Type, i, qReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		questions = append(questions, question)
+	}
+
+	rules, err := h.buildPoolRules(req.PoolRules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(questions) == 0 && len(rules) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Тест должен содержать вопросы или правила выборки из банков"})
+		return
+	}
 
 	// Создаем тест
 	test := models.Test{
@@ -379,6 +651,16 @@ func (h *Handlers) CreateTest(c *gin.Context) {
 		Title:       req.Title,
 		Description: req.Description,
 		Type:        req.Type,
+		ScoringMode: req.ScoringMode,
+		ShowCorrectAnswers: req.ShowCorrectAnswers,
+		TimeLimit:   req.TimeLimit,
+		AllowRetake: req.AllowRetake,
+		MaxAttempts: req.MaxAttempts,
+		RetakeCooldown: req.RetakeCooldown,
+		ScorePolicy: req.ScorePolicy,
+		ShuffleQuestions: req.ShuffleQuestions,
+		ShuffleOptions: req.ShuffleOptions,
+		Deadline:    req.Deadline,
 	}
 
 	if err := h.DB.Create(&test).Error; err != nil {
@@ -387,44 +669,27 @@ func (h *Handlers) CreateTest(c *gin.Context) {
 	}
 
 	// Создаем вопросы
-	for i, qReq := range req.Questions {
-		// Валидация правильного ответа
-		if qReq.CorrectAnswer < 0 || qReq.CorrectAnswer >= len(qReq.Options) {
-			h.DB.Delete(&test)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный индекс правильного ответа для вопроса"})
-			return
-		}
-
-		// Сериализуем варианты ответов в JSON
-		optionsJSON, err := json.Marshal(qReq.Options)
-		if err != nil {
+	for _, question := range questions {
+		question.TestID = &test.ID
+		if err := h.DB.Create(&question).Error; err != nil {
 			h.DB.Delete(&test)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка сериализации вариантов ответов"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания вопроса"})
 			return
 		}
+	}
 
-		question := models.TestQuestion{
-			TestID:        test.ID,
-			Question:      qReq.Question,
-			Options:       string(optionsJSON),
-			CorrectAnswer: qReq.CorrectAnswer,
-			Order:         qReq.Order,
-		}
-
-		// Если порядок не указан, используем индекс
-		if question.Order == 0 {
-			question.Order = i + 1
-		}
-
-		if err := h.DB.Create(&question).Error; err != nil {
+	// Создаем правила выборки из банков
+	for _, rule := range rules {
+		rule.TestID = test.ID
+		if err := h.DB.Create(&rule).Error; err != nil {
 			h.DB.Delete(&test)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания вопроса"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания правила выборки"})
 			return
 		}
 	}
 
 	// Загружаем с уроком и вопросами для ответа
-	h.DB.Preload("Lesson").Preload("Questions").First(&test, test.ID)
+	h.DB.Preload("Lesson").Preload("Questions").Preload("PoolRules").First(&test, test.ID)
 	c.JSON(http.StatusCreated, test)
 }
 
@@ -433,7 +698,20 @@ type UpdateTestRequest struct {
 	Title       string                    `json:"title"`
 	Description string                    `json:"description"`
 	Type        string                    `json:"type"`
+	ScoringMode string                    `json:"scoring_mode"`
+	ShowCorrectAnswers *bool               `json:"show_correct_answers"`
+	TimeLimit   *int                      `json:"time_limit" binding:"omitempty,min=0"`
+	AllowRetake *bool                     `json:"allow_retake"`
+	MaxAttempts *int                      `json:"max_attempts" binding:"omitempty,min=0"`
+	RetakeCooldown *int                   `json:"retake_cooldown" binding:"omitempty,min=0"`
+	ScorePolicy string                    `json:"score_policy"`
+	ShuffleQuestions *bool                `json:"shuffle_questions"`
+	ShuffleOptions *bool                  `json:"shuffle_options"`
 	Questions   []CreateTestQuestionRequest `json:"questions"`
+	PoolRules   *[]TestPoolRuleRequest      `json:"pool_rules"` // Если передано, правила заменяются целиком
+	Regrade     bool                        `json:"regrade"`    // Пересчитать завершенные попытки по новым ключам
+	RegradeReason string                    `json:"regrade_reason"`
+	models.Deadline                           // Отсутствующие поля не меняются, null очищает дату
 }
 
 // UpdateTest обновляет тест (только для админа)
@@ -447,6 +725,7 @@ func (h *Handlers) UpdateTest(c *gin.Context) {
 	}
 
 	var req UpdateTestRequest
+	req.Deadline = test.Deadline
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -466,48 +745,150 @@ func (h *Handlers) UpdateTest(c *gin.Context) {
 		}
 		test.Type = req.Type
 	}
+	if req.ScoringMode != "" {
+		if !pkg.IsValidScoringMode(req.ScoringMode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scoring_mode должен быть 'all_or_nothing' или 'partial'"})
+			return
+		}
+		test.ScoringMode = req.ScoringMode
+	}
+	if req.ShowCorrectAnswers != nil {
+		test.ShowCorrectAnswers = *req.ShowCorrectAnswers
+	}
+	if req.TimeLimit != nil {
+		test.TimeLimit = *req.TimeLimit
+	}
+	if req.AllowRetake != nil {
+		test.AllowRetake = *req.AllowRetake
+	}
+	if req.MaxAttempts != nil {
+		test.MaxAttempts = *req.MaxAttempts
+	}
+	if req.RetakeCooldown != nil {
+		test.RetakeCooldown = *req.RetakeCooldown
+	}
+	if req.ScorePolicy != "" {
+		if !models.IsValidScorePolicy(req.ScorePolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "score_policy должен быть 'best', 'last', 'average' или 'first'"})
+			return
+		}
+		test.ScorePolicy = req.ScorePolicy
+	}
+	if req.ShuffleQuestions != nil {
+		test.ShuffleQuestions = *req.ShuffleQuestions
+	}
+	if req.ShuffleOptions != nil {
+		test.ShuffleOptions = *req.ShuffleOptions
+	}
+	if err := validateDeadline(req.Deadline); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	test.Deadline = req.Deadline
 
-	h.DB.Save(&test)
+	var rules []models.TestPoolRule
+	if req.PoolRules != nil {
+		var err error
+		if rules, err = h.buildPoolRules(*req.PoolRules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	// Если переданы вопросы, обновляем их
-	if len(req.Questions) > 0 {
-		// Удаляем старые вопросы
-		h.DB.Where("test_id = ?", test.ID).Delete(&models.TestQuestion{})
+	// Текущие вопросы теста: вопросы с ID в запросе обновляются, сохраняя идентичность
+	var existing []models.TestQuestion
+	h.DB.Where("test_id = ?", test.ID).Find(&existing)
+	existingByID := make(map[uint]models.TestQuestion, len(existing))
+	for _, q := range existing {
+		existingByID[q.ID] = q
+	}
 
-		// Создаем новые вопросы
-		for i, qReq := range req.Questions {
-			if qReq.CorrectAnswer < 0 || qReq.CorrectAnswer >= len(qReq.Options) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный индекс правильного ответа для вопроса"})
+	// Проверяем вопросы до изменения теста
+	questions := make([]models.TestQuestion, 0, len(req.Questions))
+	kept := make(map[uint]bool, len(req.Questions))
+	for i, qReq := range req.Questions {
+		question, err := buildTestQuestion(test.Type, i, qReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if qReq.ID != 0 {
+			old, ok := existingByID[qReq.ID]
+			if !ok || kept[qReq.ID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Вопрос " + strconv.Itoa(i+1) + ": вопрос с ID " + strconv.Itoa(int(qReq.ID)) + " не найден в тесте"})
 				return
 			}
+			question.ID = old.ID
+			question.CreatedAt = old.CreatedAt
+			kept[qReq.ID] = true
+		}
+		question.TestID = &test.ID
+		questions = append(questions, question)
+	}
 
-			optionsJSON, err := json.Marshal(qReq.Options)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка сериализации вариантов ответов"})
+	// При смене типа на single без новых вопросов у старых должен быть ровно один правильный ответ
+	if len(questions) == 0 && test.Type != "multiple" {
+		for i := range existing {
+			if existing[i].EffectiveType(test.Type) == models.QuestionSingle && len(pkg.CorrectIndices(&existing[i])) > 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "В тесте есть вопросы с несколькими правильными ответами. Передайте вопросы заново для типа 'single'"})
 				return
 			}
+		}
+	}
+
+	adminID, _ := c.Get("user_id")
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&test).Error; err != nil {
+			return errors.New("Ошибка обновления теста")
+		}
 
-			question := models.TestQuestion{
-				TestID:        test.ID,
-				Question:      qReq.Question,
-				Options:       string(optionsJSON),
-				CorrectAnswer: qReq.CorrectAnswer,
-				Order:         qReq.Order,
+		// Если переданы правила выборки, заменяем их
+		if req.PoolRules != nil {
+			if err := tx.Where("test_id = ?", test.ID).Delete(&models.TestPoolRule{}).Error; err != nil {
+				return errors.New("Ошибка обновления правил выборки")
 			}
+			for _, rule := range rules {
+				rule.TestID = test.ID
+				if err := tx.Create(&rule).Error; err != nil {
+					return errors.New("Ошибка создания правила выборки")
+				}
+			}
+		}
 
-			if question.Order == 0 {
-				question.Order = i + 1
+		// Если переданы вопросы, синхронизируем их: обновляем, создаем, а отсутствующие в запросе удаляем
+		if len(questions) > 0 {
+			for _, q := range existing {
+				if !kept[q.ID] {
+					if err := tx.Delete(&models.TestQuestion{}, q.ID).Error; err != nil {
+						return errors.New("Ошибка удаления вопроса")
+					}
+				}
+			}
+			for _, question := range questions {
+				if err := tx.Save(&question).Error; err != nil {
+					return errors.New("Ошибка сохранения вопроса")
+				}
 			}
+		}
 
-			if err := h.DB.Create(&question).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания вопроса"})
-				return
+		if req.Regrade {
+			reason := req.RegradeReason
+			if reason == "" {
+				reason = "Изменение теста"
+			}
+			if _, err := regradeTestAttempts(tx, gradingScope(c, "user_id"), test.ID, 0, adminID.(uint), reason); err != nil {
+				return errors.New("Ошибка пересчета попыток")
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Загружаем с вопросами для ответа
-	h.DB.Preload("Lesson").Preload("Questions").First(&test, test.ID)
+	h.DB.Preload("Lesson").Preload("Questions").Preload("PoolRules").First(&test, test.ID)
 	c.JSON(http.StatusOK, test)
 }
 
@@ -520,7 +901,7 @@ func (h *Handlers) DeleteTest(c *gin.Context) {
 
 // CreateTestAttemptRequest структура запроса прохождения теста
 type CreateTestAttemptRequest struct {
-	Answers string `json:"answers" binding:"required"` // JSON строка с ответами
+	Answers string `json:"answers" binding:"required"` // JSON строка с ответами {question_id: ответ}, формат ответа зависит от типа вопроса
 }
 
 // CreateTestAttempt создает попытку прохождения теста
@@ -536,66 +917,68 @@ func (h *Handlers) CreateTestAttempt(c *gin.Context) {
 
 	// Получаем тест с вопросами для проверки правильных ответов
 	var test models.Test
-	if err := h.DB.Preload("Questions").First(&test, testID).Error; err != nil {
+	if err := h.DB.Preload("Questions").Preload("PoolRules").Scopes(visibleContent(c, models.ContentTest)).First(&test, testID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
 		return
 	}
 
-	// Проверяем, можно ли проходить тест повторно
-	if !test.AllowRetake {
-		var existingAttempt models.TestAttempt
-		if err := h.DB.Where("user_id = ? AND test_id = ?", userID, testID).First(&existingAttempt).Error; err == nil {
-			// Попытка уже существует и повторное прохождение запрещено
-			c.JSON(http.StatusForbidden, gin.H{"error": "Тест можно пройти только один раз. Повторное прохождение запрещено администратором."})
-			return
-		}
+	// Тест с ограничением времени или индивидуальной выборкой вопросов проходится только через начало попытки
+	if test.RequiresSession() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Этот тест проходится через начало попытки: /tests/:id/start"})
+		return
 	}
 
-	// Парсим ответы пользователя {question_id: answer_index}
-	var userAnswers map[string]interface{}
+	// Проверяем окно сдачи с учетом продления
+	now := time.Now()
+	window := effectiveDeadline(h.DB, models.AssignmentTest, test.ID, test.Deadline, userID)
+	if err := window.CheckOpen(now); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Парсим ответы пользователя {question_id: ответ}
+	var userAnswers map[string]json.RawMessage
 	if err := json.Unmarshal([]byte(req.Answers), &userAnswers); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный формат ответов"})
 		return
 	}
 
 	// Подсчитываем баллы
-	score := 0.0
-	total := float64(len(test.Questions))
-	
-	for _, question := range test.Questions {
-		questionIDStr := strconv.Itoa(int(question.ID))
-		if userAnswer, ok := userAnswers[questionIDStr]; ok {
-			// Преобразуем ответ пользователя в число
-			var userAnswerInt int
-			switch v := userAnswer.(type) {
-			case float64:
-				userAnswerInt = int(v)
-			case int:
-				userAnswerInt = v
-			default:
-				continue
-			}
-			
-			// Проверяем правильность ответа
-			if userAnswerInt == question.CorrectAnswer {
-				score++
-			}
-		}
-	}
-	
-	finalScore := 0.0
-	if total > 0 {
-		finalScore = (score / total) * 100
-	}
+	results, rawScore := pkg.GradeAttempt(&test, test.Questions, userAnswers, nil)
+	isLate, penalty := window.LatePenaltyAt(now)
 
 	attempt := models.TestAttempt{
-		UserID:  userID.(uint),
-		TestID:  uint(testID),
-		Answers: req.Answers,
-		Score:   finalScore,
-	}
-
-	if err := h.DB.Create(&attempt).Error; err != nil {
+		UserID:      userID.(uint),
+		TestID:      uint(testID),
+		Answers:     req.Answers,
+		Score:       models.ApplyLatePenalty(rawScore, penalty),
+		RawScore:    rawScore,
+		IsLate:      isLate,
+		LatePenalty: penalty,
+		Status:      models.AttemptSubmitted,
+		StartedAt:   &now,
+		SubmittedAt: &now,
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockAttempts(tx, userID); err != nil {
+			return err
+		}
+		// Проверяем лимит попыток и интервал между ними
+		if err := checkRetakePolicy(tx, &test, userID); err != nil {
+			return err
+		}
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return pkg.SaveAttemptResults(tx, attempt.ID, results)
+	})
+	var denied retakeError
+	switch {
+	case errors.As(err, &denied):
+		c.JSON(http.StatusForbidden, gin.H{"error": denied.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания попытки"})
 		return
 	}
@@ -614,20 +997,18 @@ func (h *Handlers) GetUserTestAttempts(c *gin.Context) {
 
 // GetTestAttempt возвращает попытку по ID
 func (h *Handlers) GetTestAttempt(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	userID, _ := c.Get("user_id")
-	
-	var attempt models.TestAttempt
-	if err := h.DB.Preload("Test").First(&attempt, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Попытка не найдена"})
+	// Только владелец или админ
+	attempt, ok := loadOwned[models.TestAttempt](c, h.DB.Preload("Test"), true, "Попытка не найдена")
+	if !ok {
 		return
 	}
 
-	// Проверяем доступ (только владелец или админ)
-	role, _ := c.Get("user_role")
-	if attempt.UserID != userID.(uint) && role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Нет доступа"})
-		return
+	// Разбор по вопросам доступен после завершения попытки,
+	// ключ ответов студент видит, только когда пересдать тест уже нельзя
+	if attempt.IsFinished() {
+		showKey := isStaff(c) ||
+			attempt.Test.ShowCorrectAnswers && h.revealedTestIDs(attempt.UserID, []models.Test{attempt.Test})[attempt.TestID]
+		attempt.Review = h.buildAttemptReview(attempt, showKey)
 	}
 
 	c.JSON(http.StatusOK, attempt)
@@ -636,7 +1017,15 @@ func (h *Handlers) GetTestAttempt(c *gin.Context) {
 // GetAllTestAttempts возвращает все попытки с оценками (только для админа)
 func (h *Handlers) GetAllTestAttempts(c *gin.Context) {
 	var attempts []models.TestAttempt
-	h.DB.Preload("User").Preload("Test").Preload("Test.Lesson").Find(&attempts)
+	h.DB.Preload("User").Preload("Test").Preload("Test.Lesson").Scopes(gradingScope(c, "user_id")).Order("created_at ASC").Find(&attempts)
+
+	// Группируем попытки по студенту и тесту для подсчета итогового балла
+	type userTest struct{ userID, testID uint }
+	grouped := make(map[userTest][]models.TestAttempt)
+	for _, attempt := range attempts {
+		key := userTest{attempt.UserID, attempt.TestID}
+		grouped[key] = append(grouped[key], attempt)
+	}
 	
 	// Загружаем оценки для каждой попытки
 	var attemptsWithGrades []map[string]interface{}
@@ -648,11 +1037,23 @@ func (h *Handlers) GetAllTestAttempts(c *gin.Context) {
 			"test_id":    attempt.TestID,
 			"answers":    attempt.Answers,
 			"score":      attempt.Score,
+			"raw_score":  attempt.RawScore,
+			"is_late":    attempt.IsLate,
+			"late_penalty": attempt.LatePenalty,
+			"status":     attempt.Status,
+			"started_at": attempt.StartedAt,
+			"deadline":   attempt.Deadline,
+			"submitted_at": attempt.SubmittedAt,
 			"created_at": attempt.CreatedAt,
 			"updated_at": attempt.UpdatedAt,
 			"user":       attempt.User,
 			"test":       attempt.Test,
 		}
+
+		// Итоговый балл студента по тесту с учетом правила score_policy
+		if effective, ok := attempt.Test.EffectiveScore(grouped[userTest{attempt.UserID, attempt.TestID}]); ok {
+			attemptData["effective_score"] = effective
+		}
 		
 		// Ищем оценку для этой попытки
 		err := h.DB.Where("attempt_id = ?", attempt.ID).First(&grade).Error
@@ -668,13 +1069,16 @@ func (h *Handlers) GetAllTestAttempts(c *gin.Context) {
 
 // DeleteTestAttempt удаляет попытку теста и связанные оценки (разрешить пересдачу) (только для админа)
 func (h *Handlers) DeleteTestAttempt(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	attempt, ok := loadOwned[models.TestAttempt](c, h.DB, true, "Попытка не найдена")
+	if !ok {
+		return
+	}
 	
 	// Удаляем оценки, связанные с этой попыткой
-	h.DB.Where("attempt_id = ?", id).Delete(&models.TestGrade{})
+	h.DB.Where("attempt_id = ?", attempt.ID).Delete(&models.TestGrade{})
 	
 	// Удаляем саму попытку
-	if err := h.DB.Delete(&models.TestAttempt{}, id).Error; err != nil {
+	if err := h.DB.Delete(&models.TestAttempt{}, attempt.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить попытку"})
 		return
 	}
@@ -686,9 +1090,19 @@ func (h *Handlers) DeleteTestAttempt(c *gin.Context) {
 type CreateTestGradeRequest struct {
 	UserID    uint    `json:"user_id" binding:"required"`
 	TestID    uint    `json:"test_id" binding:"required"`
-	AttemptID *uint   `json:"attempt_id"`
-	Grade     float64 `json:"grade" binding:"required"`
-	Comment   string  `json:"comment"`
+	AttemptID *uint    `json:"attempt_id"`
+	Grade     *float64 `json:"grade" binding:"required,min=0,max=100"`
+	Scale     string   `json:"scale"` // five (по умолчанию) или percent; при обновлении - прежняя шкала
+	Comment   string   `json:"comment"`
+}
+
+// gradeScale проверяет оценку по шкале из запроса и возвращает шкалу.
+// Без scale в запросе остается шкала stored (для новой оценки - пятибалльная).
+func gradeScale(grade float64, requested, stored string) (string, error) {
+	if requested == "" {
+		requested = stored
+	}
+	return models.CheckGradeScale(grade, requested)
 }
 
 // CreateTestGrade создает или обновляет оценку теста (только для админа)
@@ -698,14 +1112,24 @@ func (h *Handlers) CreateTestGrade(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.canGradeUser(c, req.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Студент не входит в ваши группы"})
+		return
+	}
 
 	// Проверяем, существует ли уже оценка для этого пользователя и теста
 	var existingGrade models.TestGrade
 	err := h.DB.Where("user_id = ? AND test_id = ?", req.UserID, req.TestID).First(&existingGrade).Error
-	
+	scale, scaleErr := gradeScale(*req.Grade, req.Scale, existingGrade.Scale)
+	if scaleErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": scaleErr.Error()})
+		return
+	}
+
 	if err == nil {
 		// Оценка найдена - обновляем её
-		existingGrade.Grade = req.Grade
+		existingGrade.Grade = *req.Grade
+		existingGrade.Scale = scale
 		existingGrade.Comment = req.Comment
 		if req.AttemptID != nil {
 			existingGrade.AttemptID = *req.AttemptID
@@ -724,7 +1148,8 @@ func (h *Handlers) CreateTestGrade(c *gin.Context) {
 	grade := models.TestGrade{
 		UserID:  req.UserID,
 		TestID:  req.TestID,
-		Grade:   req.Grade,
+		Grade:   *req.Grade,
+		Scale:   scale,
 		Comment: req.Comment,
 	}
 	
@@ -740,30 +1165,86 @@ func (h *Handlers) CreateTestGrade(c *gin.Context) {
 	c.JSON(http.StatusCreated, grade)
 }
 
+// UpdateGradeRequest структура запроса обновления оценки.
+// Студент и задание оценки не меняются: их можно задать только при создании.
+type UpdateGradeRequest struct {
+	Grade   *float64 `json:"grade" binding:"required,min=0,max=100"`
+	Comment string   `json:"comment"`
+}
+
+// UpdateScaledGradeRequest структура запроса обновления оценки теста или практики
+type UpdateScaledGradeRequest struct {
+	UpdateGradeRequest
+	Scale string `json:"scale"` // five или percent; без поля остается прежняя шкала
+}
+
 // UpdateTestGrade обновляет оценку теста (только для админа)
 func (h *Handlers) UpdateTestGrade(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	
-	var grade models.TestGrade
-	if err := h.DB.First(&grade, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Оценка не найдена"})
+	grade, ok := loadOwned[models.TestGrade](c, h.DB, true, "Оценка не найдена")
+	if !ok {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&grade); err != nil {
+	var req UpdateScaledGradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scale, err := gradeScale(*req.Grade, req.Scale, grade.Scale)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	h.DB.Save(&grade)
+	grade.Grade = *req.Grade
+	grade.Scale = scale
+	grade.Comment = req.Comment
+	if err := h.DB.Model(grade).Select("grade", "scale", "comment").Updates(grade).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления оценки"})
+		return
+	}
 	c.JSON(http.StatusOK, grade)
 }
 
+// deleteScoped удаляет запись в пределах gradingScope; чужая и несуществующая запись дают 404
+func (h *Handlers) deleteScoped(c *gin.Context, record interface{}, notFound, deleted string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+
+	result := h.DB.Scopes(gradingScope(c, "user_id")).Delete(record, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": deleted})
+}
+
 // DeleteTestGrade удаляет оценку теста (только для админа)
 func (h *Handlers) DeleteTestGrade(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	h.DB.Delete(&models.TestGrade{}, id)
-	c.JSON(http.StatusOK, gin.H{"message": "Оценка удалена"})
+	h.deleteScoped(c, &models.TestGrade{}, "Оценка не найдена", "Оценка удалена")
+}
+
+// userAttemptsByTest возвращает попытки пользователя по тестам в порядке создания
+// и ID тестов в порядке первой попытки
+func (h *Handlers) userAttemptsByTest(userID interface{}) (map[uint][]models.TestAttempt, []uint) {
+	var attempts []models.TestAttempt
+	h.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&attempts)
+	byTest := make(map[uint][]models.TestAttempt)
+	var testIDs []uint
+	for _, attempt := range attempts {
+		if _, ok := byTest[attempt.TestID]; !ok {
+			testIDs = append(testIDs, attempt.TestID)
+		}
+		byTest[attempt.TestID] = append(byTest[attempt.TestID], attempt)
+	}
+	return byTest, testIDs
 }
 
 // GetUserTestGrades возвращает оценки тестов текущего пользователя
@@ -772,13 +1253,87 @@ func (h *Handlers) GetUserTestGrades(c *gin.Context) {
 	
 	var grades []models.TestGrade
 	h.DB.Where("user_id = ?", userID).Preload("Test").Preload("Attempt").Find(&grades)
+
+	// Добавляем итоговый автоматический балл по правилу теста
+	byTest, _ := h.userAttemptsByTest(userID)
+	for i := range grades {
+		grades[i].AttemptsCount = len(byTest[grades[i].TestID])
+		if effective, ok := grades[i].Test.EffectiveScore(byTest[grades[i].TestID]); ok {
+			grades[i].EffectiveScore = &effective
+		}
+	}
+
 	c.JSON(http.StatusOK, grades)
 }
 
+// TestResultEntry итог по тесту для студента: итоговый автоматический балл
+// и поля оценки преподавателя, если она выставлена (graded)
+type TestResultEntry struct {
+	*models.TestGrade
+	TestID         uint        `json:"test_id"`
+	Test           models.Test `json:"test"`
+	AttemptsCount  int         `json:"attempts_count"`
+	EffectiveScore *float64    `json:"effective_score,omitempty"` // Итоговый автоматический балл по правилу теста
+	Graded         bool        `json:"graded"`
+}
+
+// GetUserTestResults возвращает итоги тестов текущего пользователя: все тесты с завершенными
+// попытками, в том числе еще не оцененные преподавателем, и все тесты с оценкой преподавателя
+func (h *Handlers) GetUserTestResults(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var grades []models.TestGrade
+	h.DB.Where("user_id = ?", userID).Preload("Attempt").Find(&grades)
+
+	byTest, testIDs := h.userAttemptsByTest(userID)
+	for _, grade := range grades {
+		if _, ok := byTest[grade.TestID]; !ok {
+			testIDs = append(testIDs, grade.TestID)
+		}
+	}
+
+	var tests []models.Test
+	if len(testIDs) > 0 {
+		h.DB.Where("id IN ?", testIDs).Find(&tests)
+	}
+	testsByID := make(map[uint]*models.Test, len(tests))
+	for i := range tests {
+		testsByID[tests[i].ID] = &tests[i]
+	}
+
+	newEntry := func(testID uint, grade *models.TestGrade) TestResultEntry {
+		entry := TestResultEntry{TestGrade: grade, TestID: testID, AttemptsCount: len(byTest[testID]), Graded: grade != nil}
+		if test, ok := testsByID[testID]; ok {
+			entry.Test = *test
+			if effective, ok := test.EffectiveScore(byTest[testID]); ok {
+				entry.EffectiveScore = &effective
+			}
+		}
+		return entry
+	}
+
+	entries := make([]TestResultEntry, 0, len(testIDs))
+	graded := make(map[uint]bool, len(grades))
+	for i := range grades {
+		entries = append(entries, newEntry(grades[i].TestID, &grades[i]))
+		graded[grades[i].TestID] = true
+	}
+	for _, testID := range testIDs {
+		if graded[testID] {
+			continue
+		}
+		if entry := newEntry(testID, nil); entry.EffectiveScore != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
 // GetPractices возвращает список практических заданий
 func (h *Handlers) GetPractices(c *gin.Context) {
 	var practices []models.Practice
-	h.DB.Preload("Lesson").Find(&practices)
+	h.DB.Preload("Lesson").Scopes(visibleContent(c, models.ContentPractice)).Find(&practices)
 	c.JSON(http.StatusOK, practices)
 }
 
@@ -791,7 +1346,7 @@ func (h *Handlers) GetPractice(c *gin.Context) {
 	}
 	
 	var practice models.Practice
-	if err := h.DB.Preload("Lesson").First(&practice, id).Error; err != nil {
+	if err := h.DB.Preload("Lesson").Scopes(visibleContent(c, models.ContentPractice)).First(&practice, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Практическое задание не найдено"})
 		return
 	}
@@ -806,6 +1361,10 @@ func (h *Handlers) CreatePractice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateDeadline(practice.Deadline); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := h.DB.Create(&practice).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания практического задания"})
@@ -829,6 +1388,10 @@ func (h *Handlers) UpdatePractice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateDeadline(practice.Deadline); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	h.DB.Save(&practice)
 	c.JSON(http.StatusOK, practice)
@@ -844,11 +1407,18 @@ func (h *Handlers) DeletePractice(c *gin.Context) {
 // SubmitPracticeRequest структура запроса отправки практического задания
 type SubmitPracticeRequest struct {
 	FileURL string `json:"file_url" binding:"required"`
+	Comment string `json:"comment"`
 }
 
-// SubmitPractice отправляет практическое задание
+// SubmitPractice отправляет практическое задание.
+// Повторная отправка создает новую версию и возможна, только если предыдущая возвращена на доработку.
+// Опоздание определяется по первой версии.
 func (h *Handlers) SubmitPractice(c *gin.Context) {
-	practiceID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	practiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || practiceID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID задания"})
+		return
+	}
 	userID, _ := c.Get("user_id")
 	
 	var req SubmitPracticeRequest
@@ -857,13 +1427,54 @@ func (h *Handlers) SubmitPractice(c *gin.Context) {
 		return
 	}
 
-	submit := models.PracticeSubmit{
-		UserID:     userID.(uint),
-		PracticeID: uint(practiceID),
-		FileURL:    req.FileURL,
-	}
+	var submit models.PracticeSubmit
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Блокируем задание, чтобы параллельные отправки не получили один номер версии
+		var practice models.Practice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(visibleContent(c, models.ContentPractice)).First(&practice, practiceID).Error; err != nil {
+			return errPracticeNotFound
+		}
+
+		now := time.Now()
+		window := effectiveDeadline(tx, models.AssignmentPractice, practice.ID, practice.Deadline, userID)
+		if err := window.CheckOpen(now); err != nil {
+			return err
+		}
+
+		var last models.PracticeSubmit
+		version := 1
+		isLate, penalty := window.LatePenaltyAt(now)
+		if err := tx.Where("user_id = ? AND practice_id = ?", userID, practiceID).Order("version DESC").First(&last).Error; err == nil {
+			if !last.CanResubmit() {
+				return errResubmitNotAllowed
+			}
+			version = last.Version + 1
+			isLate, penalty = last.IsLate, last.LatePenalty
+		}
 
-	if err := h.DB.Create(&submit).Error; err != nil {
+		submit = models.PracticeSubmit{
+			UserID:     userID.(uint),
+			PracticeID: uint(practiceID),
+			FileURL:    req.FileURL,
+			Comment:    req.Comment,
+			Version:    version,
+			Status:     models.SubmitSubmitted,
+			IsLate:     isLate,
+			LatePenalty: penalty,
+		}
+		return tx.Create(&submit).Error
+	})
+	switch {
+	case errors.Is(err, errPracticeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задание не найдено"})
+		return
+	case errors.Is(err, errResubmitNotAllowed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrAssignmentNotOpen), errors.Is(err, models.ErrAssignmentClosed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка отправки задания"})
 		return
 	}
@@ -871,40 +1482,74 @@ func (h *Handlers) SubmitPractice(c *gin.Context) {
 	c.JSON(http.StatusCreated, submit)
 }
 
-// GetUserPracticeSubmits возвращает отправки практических заданий текущего пользователя
+// GetUserPracticeSubmits возвращает отправки текущего пользователя, сгруппированные по заданиям:
+// текущий статус, последняя версия, история версий и оценка
 func (h *Handlers) GetUserPracticeSubmits(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	
 	var submits []models.PracticeSubmit
-	h.DB.Where("user_id = ?", userID).Preload("Practice").Find(&submits)
-	c.JSON(http.StatusOK, submits)
+	h.DB.Where("user_id = ?", userID).Preload("Practice").Preload("Comments").
+		Order("practice_id ASC, version DESC").Find(&submits)
+
+	var grades []models.PracticeGrade
+	h.DB.Where("user_id = ?", userID).Find(&grades)
+	gradeByPractice := make(map[uint]*models.PracticeGrade, len(grades))
+	for i := range grades {
+		gradeByPractice[grades[i].PracticeID] = &grades[i]
+	}
+
+	threads := []models.PracticeSubmitThread{}
+	for _, submit := range submits {
+		practice := submit.Practice
+		submit.Practice = models.Practice{}
+
+		// Версии идут от новой к старой, последняя версия открывает ветку задания
+		if len(threads) == 0 || threads[len(threads)-1].Practice.ID != submit.PracticeID {
+			threads = append(threads, models.PracticeSubmitThread{
+				Practice: practice,
+				Status:   submit.Status,
+				Version:  submit.Version,
+				Current:  submit,
+				Grade:    gradeByPractice[submit.PracticeID],
+			})
+		}
+		thread := &threads[len(threads)-1]
+		thread.Versions = append(thread.Versions, submit)
+	}
+
+	c.JSON(http.StatusOK, threads)
 }
 
 // GetPracticeSubmit возвращает отправку по ID
 func (h *Handlers) GetPracticeSubmit(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	userID, _ := c.Get("user_id")
-	
-	var submit models.PracticeSubmit
-	if err := h.DB.Preload("Practice").First(&submit, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Отправка не найдена"})
-		return
-	}
-
-	// Проверяем доступ
-	role, _ := c.Get("user_role")
-	if submit.UserID != userID.(uint) && role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Нет доступа"})
+	// Только владелец или админ
+	submit, ok := loadOwned[models.PracticeSubmit](c, h.DB.Preload("Practice").Preload("Comments").Preload("Comments.Author"), true, "Отправка не найдена")
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, submit)
 }
 
-// GetAllPracticeSubmits возвращает все отправки (только для админа)
+// GetAllPracticeSubmits возвращает все отправки с фильтрами practice_id, user_id, status;
+// latest=true оставляет только последние версии (только для админа)
 func (h *Handlers) GetAllPracticeSubmits(c *gin.Context) {
+	query := h.DB.Preload("User").Preload("Practice").Preload("Comments").Scopes(gradingScope(c, "user_id"))
+	if practiceID := c.Query("practice_id"); practiceID != "" {
+		query = query.Where("practice_id = ?", practiceID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if c.Query("latest") == "true" {
+		query = query.Where("version = (SELECT MAX(ps.version) FROM practice_submits ps WHERE ps.user_id = practice_submits.user_id AND ps.practice_id = practice_submits.practice_id AND ps.deleted_at IS NULL)")
+	}
+
 	var submits []models.PracticeSubmit
-	h.DB.Preload("User").Preload("Practice").Find(&submits)
+	query.Order("created_at ASC").Find(&submits)
 	c.JSON(http.StatusOK, submits)
 }
 
@@ -912,9 +1557,10 @@ func (h *Handlers) GetAllPracticeSubmits(c *gin.Context) {
 type CreatePracticeGradeRequest struct {
 	UserID     uint    `json:"user_id" binding:"required"`
 	PracticeID uint    `json:"practice_id" binding:"required"`
-	SubmitID   *uint   `json:"submit_id"`
-	Grade      float64 `json:"grade" binding:"required"`
-	Comment    string  `json:"comment"`
+	SubmitID   *uint    `json:"submit_id"`
+	Grade      *float64 `json:"grade" binding:"required,min=0,max=100"`
+	Scale      string   `json:"scale"` // five (по умолчанию) или percent; при обновлении - прежняя шкала
+	Comment    string   `json:"comment"`
 }
 
 // CreatePracticeGrade создает или обновляет оценку практического задания (только для админа)
@@ -924,18 +1570,28 @@ func (h *Handlers) CreatePracticeGrade(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.canGradeUser(c, req.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Студент не входит в ваши группы"})
+		return
+	}
 
 	// Проверяем, существует ли уже оценка для этого пользователя и практики
 	var existingGrade models.PracticeGrade
 	err := h.DB.Where("user_id = ? AND practice_id = ?", req.UserID, req.PracticeID).First(&existingGrade).Error
-	
+	scale, scaleErr := gradeScale(*req.Grade, req.Scale, existingGrade.Scale)
+	if scaleErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": scaleErr.Error()})
+		return
+	}
+
 	if err == nil {
 		// Оценка найдена - обновляем её
-		existingGrade.Grade = req.Grade
+		existingGrade.Scale = scale
 		existingGrade.Comment = req.Comment
 		if req.SubmitID != nil {
 			existingGrade.SubmitID = *req.SubmitID
 		}
+		h.setPracticeGrade(&existingGrade, *req.Grade)
 		
 		if err := h.DB.Save(&existingGrade).Error; err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка обновления оценки"})
@@ -950,13 +1606,14 @@ func (h *Handlers) CreatePracticeGrade(c *gin.Context) {
 	grade := models.PracticeGrade{
 		UserID:     req.UserID,
 		PracticeID: req.PracticeID,
-		Grade:      req.Grade,
+		Scale:      scale,
 		Comment:    req.Comment,
 	}
 	
 	if req.SubmitID != nil {
 		grade.SubmitID = *req.SubmitID
 	}
+	h.setPracticeGrade(&grade, *req.Grade)
 
 	if err := h.DB.Create(&grade).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания оценки"})
@@ -968,28 +1625,36 @@ func (h *Handlers) CreatePracticeGrade(c *gin.Context) {
 
 // UpdatePracticeGrade обновляет оценку практического задания (только для админа)
 func (h *Handlers) UpdatePracticeGrade(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	
-	var grade models.PracticeGrade
-	if err := h.DB.First(&grade, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Оценка не найдена"})
+	grade, ok := loadOwned[models.PracticeGrade](c, h.DB, true, "Оценка не найдена")
+	if !ok {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&grade); err != nil {
+	// В запросе передается оценка до штрафа
+	var req UpdateScaledGradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	scale, err := gradeScale(*req.Grade, req.Scale, grade.Scale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.setPracticeGrade(grade, *req.Grade)
+	grade.Scale = scale
+	grade.Comment = req.Comment
 
-	h.DB.Save(&grade)
+	if err := h.DB.Model(grade).Select("grade", "raw_grade", "late_penalty", "scale", "comment").Updates(grade).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления оценки"})
+		return
+	}
 	c.JSON(http.StatusOK, grade)
 }
 
 // DeletePracticeGrade удаляет оценку практического задания (только для админа)
 func (h *Handlers) DeletePracticeGrade(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	h.DB.Delete(&models.PracticeGrade{}, id)
-	c.JSON(http.StatusOK, gin.H{"message": "Оценка удалена"})
+	h.deleteScoped(c, &models.PracticeGrade{}, "Оценка не найдена", "Оценка удалена")
 }
 
 // GetUserPracticeGrades возвращает оценки практических заданий текущего пользователя
@@ -1192,20 +1857,98 @@ func (h *Handlers) UpdateUser(c *gin.Context) {
 		user.Name = updateData.Name
 	}
 	if updateData.Email != "" {
-		user.Email = updateData.Email
+		user.Email = pkg.NormalizeEmail(updateData.Email)
 	}
+	roleChanged := false
 	if updateData.Role != "" {
+		if !models.IsValidRole(updateData.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Роль должна быть student, teacher или admin"})
+			return
+		}
+		roleChanged = updateData.Role != user.Role
 		user.Role = updateData.Role
 	}
 
-	h.DB.Save(&user)
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		// Токены со старой ролью больше не принимаются; сессии сохраняются,
+		// и после обновления токена пользователь получает новую роль
+		if roleChanged {
+			return revokeAccessTokens(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления пользователя"})
+		return
+	}
+	h.Users.Invalidate(user.ID)
+	c.JSON(http.StatusOK, user)
+}
+
+// SetUserStatus блокирует или разблокирует учетную запись (только для админа).
+// При блокировке все сессии пользователя завершаются.
+func (h *Handlers) SetUserStatus(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status != models.UserStatusActive && req.Status != models.UserStatusSuspended {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Статус должен быть active или suspended"})
+		return
+	}
+
+	currentUserID, _ := c.Get("user_id")
+	if req.Status == models.UserStatusSuspended && uint(id) == currentUserID.(uint) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя заблокировать свою учетную запись"})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("status", req.Status).Error; err != nil {
+			return err
+		}
+		if req.Status == models.UserStatusSuspended {
+			return revokeSessions(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка изменения статуса"})
+		return
+	}
+	h.Users.Invalidate(user.ID)
+
 	c.JSON(http.StatusOK, user)
 }
 
 // DeleteUser удаляет пользователя (только для админа)
 func (h *Handlers) DeleteUser(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	h.DB.Delete(&models.User{}, id)
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeSessions(tx, uint(id)); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления пользователя"})
+		return
+	}
+	h.Users.Invalidate(uint(id))
 	c.JSON(http.StatusOK, gin.H{"message": "Пользователь удален"})
 }
 
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"geografi-cheb/backend/config"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// passwordResetTokenSize - размер токена восстановления в байтах
+const passwordResetTokenSize = 32
+
+var errInvalidResetToken = errors.New("Ссылка восстановления недействительна или устарела")
+
+// newMailer создает отправителя писем по настройкам MAIL_DRIVER
+func newMailer(cfg *config.Config) (pkg.Mailer, error) {
+	switch cfg.MailDriver {
+	case config.MailDriverSMTP:
+		return &pkg.SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}, nil
+	case config.MailDriverLog:
+		return &pkg.LogMailer{Path: cfg.MailLogFile, From: cfg.MailFrom}, nil
+	}
+	return nil, fmt.Errorf("неизвестный MAIL_DRIVER %q", cfg.MailDriver)
+}
+
+// ChangePasswordRequest структура запроса смены пароля
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6,max=100"`
+}
+
+// ChangePassword меняет пароль текущего пользователя и снимает требование смены временного пароля.
+// Все сессии завершаются, для текущего устройства открывается новая.
+func (h *Handlers) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	if !pkg.CheckPassword(req.CurrentPassword, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный текущий пароль"})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Новый пароль должен отличаться от текущего"})
+		return
+	}
+
+	hashedPassword, err := pkg.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"password": hashedPassword, "must_change_password": false}).Error; err != nil {
+			return err
+		}
+		// Отзываем неиспользованные ссылки восстановления
+		if err := tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка смены пароля"})
+		return
+	}
+	h.Users.Invalidate(user.ID)
+
+	// Новая сессия без ограничения временного пароля
+	if err := h.DB.First(&user, user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка смены пароля"})
+		return
+	}
+	tokens, err := h.startSession(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":                   "Пароль изменен",
+		"token":                     tokens.AccessToken,
+		"refresh_token":             tokens.RefreshToken,
+		"expires_in":                tokens.ExpiresIn,
+		"two_factor_setup_required": tokens.TwoFactorSetupRequired,
+	})
+}
+
+// ForgotPasswordRequest структура запроса восстановления пароля
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword отправляет ссылку восстановления пароля на email.
+// Ответ не зависит от того, существует ли пользователь.
+func (h *Handlers) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "Если пользователь с таким email существует, на него отправлена ссылка для восстановления пароля"}
+
+	var user models.User
+	if err := h.DB.Where("LOWER(email) = ?", pkg.NormalizeEmail(req.Email)).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := pkg.RandomToken(passwordResetTokenSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	// Действует только последняя ссылка
+	now := time.Now()
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: pkg.HashToken(token),
+			ExpiresAt: now.Add(h.Config.PasswordResetTTL),
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания ссылки восстановления"})
+		return
+	}
+
+	link := h.Config.FrontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Здравствуйте, %s!\n\nДля смены пароля перейдите по ссылке:\n%s\n\nСсылка действует %s и может быть использована один раз. Если вы не запрашивали восстановление пароля, просто проигнорируйте это письмо.\n",
+		user.Name, link, h.Config.PasswordResetTTL)
+
+	// Отправляем в фоне, чтобы время ответа не выдавало существование пользователя
+	go func(email string) {
+		if err := h.Mailer.Send(email, "Восстановление пароля", body); err != nil {
+			log.Printf("Ошибка отправки письма восстановления пароля: %v", err)
+		}
+	}(user.Email)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPasswordRequest структура запроса установки нового пароля по токену
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6,max=100"`
+}
+
+// ResetPassword устанавливает новый пароль по одноразовому токену из письма
+// и завершает все сессии пользователя
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := pkg.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	now := time.Now()
+	var reset models.PasswordResetToken
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", pkg.HashToken(req.Token), now).
+			First(&reset).Error; err != nil {
+			return errInvalidResetToken
+		}
+
+		// Условное обновление: параллельный запрос с тем же токеном не пройдет
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+
+		result = tx.Model(&models.User{}).Where("id = ?", reset.UserID).
+			Updates(map[string]interface{}{"password": hashedPassword, "must_change_password": false})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+		return revokeSessions(tx, reset.UserID)
+	})
+	if err == errInvalidResetToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка смены пароля"})
+		return
+	}
+	h.Users.Invalidate(reset.UserID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен, войдите с новым паролем"})
+}
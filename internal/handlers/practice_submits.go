@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errPracticeNotFound   = errors.New("задание не найдено")
+	errResubmitNotAllowed = errors.New("Новую версию можно отправить только после возврата задания на доработку")
+)
+
+// submitTransitions допустимые переходы статусов отправки при проверке
+var submitTransitions = map[string][]string{
+	models.SubmitSubmitted:   {models.SubmitUnderReview, models.SubmitReturned, models.SubmitAccepted},
+	models.SubmitUnderReview: {models.SubmitReturned, models.SubmitAccepted},
+	models.SubmitReturned:    {models.SubmitUnderReview},
+	models.SubmitAccepted:    {models.SubmitUnderReview},
+}
+
+// canTransitionSubmit проверяет переход статуса отправки
+func canTransitionSubmit(from, to string) bool {
+	for _, allowed := range submitTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPracticeSubmit загружает отправку по ID из пути в пределах прав проверяющего
+func (h *Handlers) loadPracticeSubmit(c *gin.Context) (*models.PracticeSubmit, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID отправки"})
+		return nil, false
+	}
+
+	var submit models.PracticeSubmit
+	if err := h.DB.Scopes(gradingScope(c, "user_id")).First(&submit, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Отправка не найдена"})
+		return nil, false
+	}
+	return &submit, true
+}
+
+// UpdateSubmitStatusRequest структура запроса смены статуса отправки
+type UpdateSubmitStatusRequest struct {
+	Status  string `json:"status" binding:"required"` // under_review, returned, accepted
+	Comment string `json:"comment"`                   // Необязательный комментарий к версии
+}
+
+// UpdatePracticeSubmitStatus меняет статус последней версии отправки (только для админа)
+func (h *Handlers) UpdatePracticeSubmitStatus(c *gin.Context) {
+	submit, ok := h.loadPracticeSubmit(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateSubmitStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidSubmitStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status должен быть 'under_review', 'returned' или 'accepted'"})
+		return
+	}
+
+	var newer int64
+	h.DB.Model(&models.PracticeSubmit{}).
+		Where("user_id = ? AND practice_id = ? AND version > ?", submit.UserID, submit.PracticeID, submit.Version).
+		Count(&newer)
+	if newer > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Статус можно менять только у последней версии"})
+		return
+	}
+	if !canTransitionSubmit(submit.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Недопустимый переход статуса: " + submit.Status + " -> " + req.Status})
+		return
+	}
+
+	authorID, _ := c.Get("user_id")
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// Условие на старый статус защищает от одновременной смены
+		result := tx.Model(&models.PracticeSubmit{}).
+			Where("id = ? AND status = ?", submit.ID, submit.Status).
+			Update("status", req.Status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("Статус отправки уже изменен")
+		}
+		if req.Comment != "" {
+			return tx.Create(&models.PracticeSubmitComment{
+				SubmitID: submit.ID,
+				AuthorID: authorID.(uint),
+				Text:     req.Comment,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.DB.Preload("Comments").Preload("Comments.Author").First(submit, submit.ID)
+	c.JSON(http.StatusOK, submit)
+}
+
+// CreateSubmitCommentRequest структура запроса комментария к версии отправки
+type CreateSubmitCommentRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// CreatePracticeSubmitComment добавляет комментарий преподавателя к версии отправки (только для админа)
+func (h *Handlers) CreatePracticeSubmitComment(c *gin.Context) {
+	submit, ok := h.loadPracticeSubmit(c)
+	if !ok {
+		return
+	}
+
+	var req CreateSubmitCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authorID, _ := c.Get("user_id")
+	comment := models.PracticeSubmitComment{
+		SubmitID: submit.ID,
+		AuthorID: authorID.(uint),
+		Text:     req.Text,
+	}
+	if err := h.DB.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания комментария"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"geografi-cheb/backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionPoolRequest структура запроса создания/обновления банка вопросов
+type QuestionPoolRequest struct {
+	LessonID uint   `json:"lesson_id" binding:"required"`
+	Topic    string `json:"topic"`
+	Title    string `json:"title" binding:"required"`
+}
+
+// GetQuestionPools возвращает банки вопросов с фильтром по уроку и теме (только для админа)
+func (h *Handlers) GetQuestionPools(c *gin.Context) {
+	query := h.DB.Preload("Lesson")
+	if lessonID := c.Query("lesson_id"); lessonID != "" {
+		query = query.Where("lesson_id = ?", lessonID)
+	}
+	if topic := c.Query("topic"); topic != "" {
+		query = query.Where("topic = ?", topic)
+	}
+
+	var pools []models.QuestionPool
+	query.Order("lesson_id ASC, topic ASC").Find(&pools)
+	c.JSON(http.StatusOK, pools)
+}
+
+// GetQuestionPool возвращает банк вопросов с вопросами (только для админа)
+func (h *Handlers) GetQuestionPool(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID банка вопросов"})
+		return
+	}
+
+	var pool models.QuestionPool
+	if err := h.DB.Preload("Lesson").Preload("Questions").First(&pool, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Банк вопросов не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pool)
+}
+
+// CreateQuestionPool создает банк вопросов (только для админа)
+func (h *Handlers) CreateQuestionPool(c *gin.Context) {
+	var req QuestionPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pool := models.QuestionPool{
+		LessonID: req.LessonID,
+		Topic:    req.Topic,
+		Title:    req.Title,
+	}
+	if err := h.DB.Create(&pool).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания банка вопросов"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, pool)
+}
+
+// UpdateQuestionPool обновляет банк вопросов (только для админа)
+func (h *Handlers) UpdateQuestionPool(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var pool models.QuestionPool
+	if err := h.DB.First(&pool, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Банк вопросов не найден"})
+		return
+	}
+
+	var req QuestionPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pool.LessonID = req.LessonID
+	pool.Topic = req.Topic
+	pool.Title = req.Title
+	if err := h.DB.Save(&pool).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления банка вопросов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pool)
+}
+
+// DeleteQuestionPool удаляет банк вопросов, если он не используется в тестах (только для админа)
+func (h *Handlers) DeleteQuestionPool(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var rules int64
+	h.DB.Model(&models.TestPoolRule{}).Where("pool_id = ?", id).Count(&rules)
+	if rules > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Банк используется в правилах выборки тестов"})
+		return
+	}
+
+	h.DB.Where("pool_id = ?", id).Delete(&models.TestQuestion{})
+	h.DB.Delete(&models.QuestionPool{}, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Банк вопросов удален"})
+}
+
+// buildPoolQuestion собирает вопрос банка. У банка нет типа теста,
+// поэтому тип вопроса обязателен и не подставляется по умолчанию.
+func buildPoolQuestion(req CreateTestQuestionRequest) (models.TestQuestion, error) {
+	if req.Type == "" {
+		return models.TestQuestion{}, errors.New("Укажите тип вопроса банка")
+	}
+	return buildTestQuestion("", 0, req)
+}
+
+// CreatePoolQuestion добавляет вопрос в банк (только для админа)
+func (h *Handlers) CreatePoolQuestion(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var pool models.QuestionPool
+	if err := h.DB.First(&pool, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Банк вопросов не найден"})
+		return
+	}
+
+	var req CreateTestQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, err := buildPoolQuestion(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	question.PoolID = &pool.ID
+
+	if err := h.DB.Create(&question).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания вопроса"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, question)
+}
+
+// UpdatePoolQuestion обновляет вопрос банка, сохраняя его ID (только для админа)
+func (h *Handlers) UpdatePoolQuestion(c *gin.Context) {
+	poolID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	questionID, _ := strconv.ParseUint(c.Param("questionId"), 10, 32)
+
+	var existing models.TestQuestion
+	if err := h.DB.Where("pool_id = ?", poolID).First(&existing, questionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вопрос не найден"})
+		return
+	}
+
+	var req CreateTestQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, err := buildPoolQuestion(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	question.ID = existing.ID
+	question.PoolID = existing.PoolID
+	question.CreatedAt = existing.CreatedAt
+
+	if err := h.DB.Save(&question).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления вопроса"})
+		return
+	}
+
+	c.JSON(http.StatusOK, question)
+}
+
+// DeletePoolQuestion удаляет вопрос из банка (только для админа)
+func (h *Handlers) DeletePoolQuestion(c *gin.Context) {
+	poolID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	questionID, _ := strconv.ParseUint(c.Param("questionId"), 10, 32)
+
+	h.DB.Where("pool_id = ?", poolID).Delete(&models.TestQuestion{}, questionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Вопрос удален"})
+}
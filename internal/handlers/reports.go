@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"geografi-cheb/backend/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportRequest структура запроса создания/обновления доклада
+type ReportRequest struct {
+	LessonID    uint   `json:"lesson_id" binding:"required"`
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	FileURL     string `json:"file_url" binding:"required"` // URL, полученный от /upload/file
+}
+
+// validateReportRequest проверяет ссылку на загруженный файл и урок.
+// Урок должен быть доступен студенту; скрытый от его группы урок дает 404, как несуществующий.
+func (h *Handlers) validateReportRequest(c *gin.Context, req *ReportRequest) bool {
+	if !strings.HasPrefix(req.FileURL, "/uploads/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл доклада должен быть загружен через /upload/file"})
+		return false
+	}
+	var lesson models.Lesson
+	if err := h.DB.Scopes(visibleContent(c, models.ContentLesson)).First(&lesson, req.LessonID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Урок не найден"})
+		return false
+	}
+	return true
+}
+
+// loadReport загружает доклад; студенту доступны только свои доклады
+func (h *Handlers) loadReport(c *gin.Context) (*models.Report, bool) {
+	return loadOwned[models.Report](c, h.DB.Preload("Lesson").Preload("Grade"), true, "Доклад не найден")
+}
+
+// GetReports возвращает доклады текущего пользователя (фильтр lesson_id)
+func (h *Handlers) GetReports(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := h.DB.Preload("Lesson").Preload("Grade").Where("user_id = ?", userID)
+	if lessonID := c.Query("lesson_id"); lessonID != "" {
+		query = query.Where("lesson_id = ?", lessonID)
+	}
+
+	var reports []models.Report
+	query.Order("created_at DESC").Find(&reports)
+	c.JSON(http.StatusOK, reports)
+}
+
+// GetReport возвращает доклад по ID
+func (h *Handlers) GetReport(c *gin.Context) {
+	report, ok := h.loadReport(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateReport прикрепляет доклад к уроку
+func (h *Handlers) CreateReport(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.validateReportRequest(c, &req) {
+		return
+	}
+
+	report := models.Report{
+		UserID:      userID.(uint),
+		LessonID:    req.LessonID,
+		Title:       req.Title,
+		Description: req.Description,
+		FileURL:     req.FileURL,
+	}
+	if err := h.DB.Create(&report).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания доклада"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// UpdateReport обновляет свой доклад, пока он не оценен
+func (h *Handlers) UpdateReport(c *gin.Context) {
+	report, ok := h.loadReport(c)
+	if !ok {
+		return
+	}
+	if report.Grade != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Оцененный доклад нельзя изменить"})
+		return
+	}
+
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.validateReportRequest(c, &req) {
+		return
+	}
+
+	report.LessonID = req.LessonID
+	report.Title = req.Title
+	report.Description = req.Description
+	report.FileURL = req.FileURL
+	if err := h.DB.Omit("Lesson", "Grade", "User").Save(report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления доклада"})
+		return
+	}
+
+	h.DB.Preload("Lesson").First(report, report.ID)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// DeleteReport удаляет свой доклад, пока он не оценен (проверяющий может удалить доклад своего студента)
+func (h *Handlers) DeleteReport(c *gin.Context) {
+	report, ok := h.loadReport(c)
+	if !ok {
+		return
+	}
+	if report.Grade != nil && !can(c, models.PermGrade) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Оцененный доклад нельзя удалить"})
+		return
+	}
+
+	h.DB.Delete(&models.Report{}, report.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Доклад удален"})
+}
+
+// GetAllReports возвращает доклады с фильтрами lesson_id и user_id (только для админа)
+func (h *Handlers) GetAllReports(c *gin.Context) {
+	query := h.DB.Preload("User").Preload("Lesson").Preload("Grade").Scopes(gradingScope(c, "user_id"))
+	if lessonID := c.Query("lesson_id"); lessonID != "" {
+		query = query.Where("lesson_id = ?", lessonID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var reports []models.Report
+	query.Order("created_at DESC").Find(&reports)
+	c.JSON(http.StatusOK, reports)
+}
+
+// ReportFeedbackRequest структура запроса отзыва на доклад
+type ReportFeedbackRequest struct {
+	Feedback string `json:"feedback" binding:"required"`
+}
+
+// SetReportFeedback сохраняет отзыв преподавателя на доклад (только для админа)
+func (h *Handlers) SetReportFeedback(c *gin.Context) {
+	report, ok := h.loadReport(c)
+	if !ok {
+		return
+	}
+
+	var req ReportFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if err := h.DB.Model(report).Updates(map[string]interface{}{"feedback": req.Feedback, "feedback_at": now}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения отзыва"})
+		return
+	}
+	report.Feedback = req.Feedback
+	report.FeedbackAt = &now
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateReportGradeRequest структура запроса создания оценки доклада
+type CreateReportGradeRequest struct {
+	ReportID uint     `json:"report_id" binding:"required"`
+	Grade    *float64 `json:"grade" binding:"required,min=0,max=100"`
+	Comment  string   `json:"comment"`
+}
+
+// CreateReportGrade создает или обновляет оценку доклада (только для админа)
+func (h *Handlers) CreateReportGrade(c *gin.Context) {
+	var req CreateReportGradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var report models.Report
+	if err := h.DB.First(&report, req.ReportID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доклад не найден"})
+		return
+	}
+	if !h.canGradeUser(c, report.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доклад не найден"})
+		return
+	}
+
+	// Проверяем, существует ли уже оценка для этого доклада
+	var existingGrade models.ReportGrade
+	if err := h.DB.Where("report_id = ?", report.ID).First(&existingGrade).Error; err == nil {
+		existingGrade.Grade = *req.Grade
+		existingGrade.Comment = req.Comment
+		if err := h.DB.Save(&existingGrade).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка обновления оценки"})
+			return
+		}
+
+		c.JSON(http.StatusOK, existingGrade)
+		return
+	}
+
+	grade := models.ReportGrade{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+		Grade:    *req.Grade,
+		Comment:  req.Comment,
+	}
+	if err := h.DB.Create(&grade).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания оценки"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, grade)
+}
+
+// UpdateReportGrade обновляет оценку доклада (только для админа)
+func (h *Handlers) UpdateReportGrade(c *gin.Context) {
+	grade, ok := loadOwned[models.ReportGrade](c, h.DB, true, "Оценка не найдена")
+	if !ok {
+		return
+	}
+
+	var req UpdateGradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grade.Grade = *req.Grade
+	grade.Comment = req.Comment
+	if err := h.DB.Model(grade).Select("grade", "comment").Updates(grade).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления оценки"})
+		return
+	}
+	c.JSON(http.StatusOK, grade)
+}
+
+// DeleteReportGrade удаляет оценку доклада (только для админа)
+func (h *Handlers) DeleteReportGrade(c *gin.Context) {
+	h.deleteScoped(c, &models.ReportGrade{}, "Оценка не найдена", "Оценка удалена")
+}
+
+// GetUserReportGrades возвращает оценки докладов текущего пользователя
+func (h *Handlers) GetUserReportGrades(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var grades []models.ReportGrade
+	h.DB.Where("user_id = ?", userID).Preload("Report").Preload("Report.Lesson").Find(&grades)
+	c.JSON(http.StatusOK, grades)
+}
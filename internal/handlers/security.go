@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"geografi-cheb/backend/config"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newLoginLimiter создает ограничитель попыток входа по настройкам LOGIN_*
+func newLoginLimiter(db *gorm.DB, cfg *config.Config) *pkg.LoginLimiter {
+	var store pkg.LimiterStore = pkg.NewMemoryLimiterStore()
+	if cfg.LoginLimiterStore == "postgres" {
+		store = &pkg.PostgresLimiterStore{DB: db}
+	}
+	return &pkg.LoginLimiter{
+		Store:              store,
+		MaxAccountFailures: cfg.LoginMaxAccountFailures,
+		MaxIPFailures:      cfg.LoginMaxIPFailures,
+		Window:             cfg.LoginFailureWindow,
+		LockoutDuration:    cfg.LoginLockoutDuration,
+		DelayBase:          time.Second,
+		MaxDelay:           30 * time.Second,
+	}
+}
+
+// logSecurityEvent записывает событие в журнал безопасности
+func (h *Handlers) logSecurityEvent(event models.SecurityEvent) {
+	if err := h.DB.Create(&event).Error; err != nil {
+		log.Printf("Ошибка записи события безопасности %s: %v", event.Type, err)
+	}
+}
+
+// tooManyLoginAttempts отвечает 429 с временем до следующей попытки
+func tooManyLoginAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       fmt.Sprintf("Слишком много попыток входа. Повторите через %d сек.", seconds),
+		"code":        "too_many_attempts",
+		"retry_after": seconds,
+	})
+}
+
+// reserveLoginAttempt резервирует попытку входа для IP и email до проверки пароля или кода.
+// Если вход заблокирован, отвечает 429 и возвращает false.
+func (h *Handlers) reserveLoginAttempt(c *gin.Context, email string) (pkg.LoginAttempt, bool) {
+	attempt, err := h.Limiter.Attempt(c.ClientIP(), email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки попыток входа"})
+		return attempt, false
+	}
+	if attempt.Wait > 0 {
+		tooManyLoginAttempts(c, attempt.Wait)
+		return attempt, false
+	}
+	return attempt, true
+}
+
+// releaseLoginAttempt возвращает зарезервированную попытку после успешной проверки
+func (h *Handlers) releaseLoginAttempt(c *gin.Context, email string) {
+	if err := h.Limiter.Release(c.ClientIP(), email); err != nil {
+		log.Printf("Ошибка возврата попытки входа: %v", err)
+	}
+}
+
+// recordLoginFailure пишет в журнал безопасности блокировки, вызванные неудачной попыткой.
+// user - найденный по email пользователь или nil.
+func (h *Handlers) recordLoginFailure(c *gin.Context, email string, user *models.User, failure pkg.LoginAttempt) {
+	ip := c.ClientIP()
+	var userID *uint
+	if user != nil {
+		userID = &user.ID
+	}
+	if failure.AccountLocked {
+		h.logSecurityEvent(models.SecurityEvent{
+			Type:    models.SecurityEventAccountLocked,
+			UserID:  userID,
+			Email:   pkg.NormalizeEmail(email),
+			IP:      ip,
+			Details: fmt.Sprintf("%d неудачных попыток, блокировка на %s", failure.AccountFailures, h.Limiter.LockoutDuration),
+		})
+	}
+	if failure.IPLocked {
+		h.logSecurityEvent(models.SecurityEvent{
+			Type:    models.SecurityEventIPLocked,
+			IP:      ip,
+			Details: fmt.Sprintf("Блокировка на %s", h.Limiter.LockoutDuration),
+		})
+	}
+}
+
+// UnlockUser снимает блокировку входа в аккаунт (только для админа)
+func (h *Handlers) UnlockUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	if err := h.Limiter.Unlock(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка снятия блокировки"})
+		return
+	}
+
+	actorID, _ := c.Get("user_id")
+	actor := actorID.(uint)
+	h.logSecurityEvent(models.SecurityEvent{
+		Type:    models.SecurityEventAccountUnlocked,
+		UserID:  &user.ID,
+		Email:   user.Email,
+		IP:      c.ClientIP(),
+		ActorID: &actor,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Блокировка входа снята"})
+}
+
+// GetSecurityEvents возвращает журнал безопасности (только для админа),
+// новые события первыми; фильтры type и user_id, limit до 500 (по умолчанию 100)
+func (h *Handlers) GetSecurityEvents(c *gin.Context) {
+	query := h.DB.Model(&models.SecurityEvent{})
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный user_id"})
+			return
+		}
+		query = query.Where("user_id = ?", id)
+	}
+
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit должен быть от 1 до 500"})
+			return
+		}
+		limit = n
+	}
+
+	var events []models.SecurityEvent
+	query.Order("created_at DESC, id DESC").Limit(limit).Find(&events)
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// refreshTokenSize - размер refresh-токена в байтах
+const refreshTokenSize = 32
+
+var errInvalidRefreshToken = errors.New("Сессия недействительна или истекла, войдите заново")
+
+// TokenPair токены сессии
+type TokenPair struct {
+	AccessToken            string `json:"token"`
+	RefreshToken           string `json:"refresh_token"`
+	ExpiresIn              int    `json:"expires_in"`                          // Время жизни access-токена в секундах
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required,omitempty"` // До настройки 2FA доступна только она
+}
+
+// startSession создает сессию пользователя и выдает пару токенов
+func (h *Handlers) startSession(c *gin.Context, user *models.User) (TokenPair, error) {
+	refreshToken, err := pkg.RandomToken(refreshTokenSize)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:      user.ID,
+		RefreshHash: pkg.HashToken(refreshToken),
+		ExpiresAt:   now.Add(h.Config.RefreshTokenTTL),
+		UserAgent:   truncateString(c.Request.UserAgent(), 255),
+		IP:          c.ClientIP(),
+		LastUsedAt:  now,
+	}
+	if err := h.DB.Create(&session).Error; err != nil {
+		return TokenPair{}, err
+	}
+	return h.newTokenPair(user, session.ID, refreshToken)
+}
+
+func (h *Handlers) newTokenPair(user *models.User, sessionID uint, refreshToken string) (TokenPair, error) {
+	setupRequired := h.twoFactorSetupRequired(user)
+	accessToken, err := pkg.GenerateToken(user, sessionID, setupRequired)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:            accessToken,
+		RefreshToken:           refreshToken,
+		ExpiresIn:              int(pkg.AccessTokenTTL().Seconds()),
+		TwoFactorSetupRequired: setupRequired,
+	}, nil
+}
+
+// completeLogin открывает сессию после успешной проверки всех факторов и отвечает токенами
+func (h *Handlers) completeLogin(c *gin.Context, user *models.User) {
+	h.Limiter.Succeed(user.Email)
+
+	tokens, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":                     tokens.AccessToken,
+		"refresh_token":             tokens.RefreshToken,
+		"expires_in":                tokens.ExpiresIn,
+		"two_factor_setup_required": tokens.TwoFactorSetupRequired,
+		"user": gin.H{
+			"id":                   user.ID,
+			"name":                 user.Name,
+			"email":                user.Email,
+			"role":                 user.Role,
+			"must_change_password": user.MustChangePassword,
+			"two_factor_enabled":   user.TwoFactorEnabled,
+		},
+	})
+}
+
+// revokeAccessTokens делает недействительными все выданные access-токены пользователя
+func revokeAccessTokens(db *gorm.DB, userID uint) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// revokeSessions завершает все сессии пользователя вместе с их access-токенами
+func revokeSessions(db *gorm.DB, userID uint) error {
+	if err := db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return revokeAccessTokens(db, userID)
+}
+
+// RefreshTokenRequest структура запроса с refresh-токеном
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken выдает новую пару токенов. Refresh-токен одноразовый: при каждом
+// обновлении он заменяется, а повторное предъявление старого токена завершает сессию.
+func (h *Handlers) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	hash := pkg.HashToken(req.RefreshToken)
+
+	var session models.Session
+	if err := h.DB.Where("refresh_hash = ?", hash).First(&session).Error; err != nil {
+		// Замененный токен предъявлен повторно - он мог быть украден
+		var reused models.Session
+		if h.DB.Where("previous_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error == nil {
+			h.DB.Model(&reused).Update("revoked_at", now)
+			h.Users.Invalidate(reused.UserID)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+		return
+	}
+	if !session.IsActive(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, session.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+		return
+	}
+	if user.IsSuspended() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Учетная запись заблокирована", "code": "account_suspended"})
+		return
+	}
+
+	refreshToken, err := pkg.RandomToken(refreshTokenSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	// Условная замена: из параллельных запросов с одним токеном пройдет только один
+	result := h.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_hash":  pkg.HashToken(refreshToken),
+			"previous_hash": hash,
+			"last_used_at":  now,
+			"ip":            c.ClientIP(),
+			"user_agent":    truncateString(c.Request.UserAgent(), 255),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления сессии"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+		return
+	}
+
+	tokens, err := h.newTokenPair(&user, session.ID, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout завершает текущую сессию
+func (h *Handlers) Logout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	if err := h.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессии"})
+		return
+	}
+	h.Users.Invalidate(userID.(uint))
+	c.JSON(http.StatusOK, gin.H{"message": "Выход выполнен"})
+}
+
+// LogoutAll завершает все сессии пользователя на всех устройствах
+func (h *Handlers) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, userID.(uint))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессий"})
+		return
+	}
+	h.Users.Invalidate(userID.(uint))
+	c.JSON(http.StatusOK, gin.H{"message": "Выход выполнен на всех устройствах"})
+}
+
+// StartAuthCleanup периодически удаляет истекшие сессии, токены второго шага входа
+// и счетчики попыток входа в фоне до отмены ctx
+func (h *Handlers) StartAuthCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				h.DB.Where("expires_at < ?", now).Delete(&models.Session{})
+				h.DB.Where("expires_at < ?", now).Delete(&models.TwoFactorChallenge{})
+				h.Limiter.Store.Cleanup(now)
+			}
+		}
+	}()
+}
+
+// truncateString обрезает строку до limit байт, не разрывая символы UTF-8
+func truncateString(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"geografi-cheb/backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// settingBool читает логическую настройку; отсутствующая настройка - false
+func (h *Handlers) settingBool(key string) bool {
+	var setting models.Setting
+	if err := h.DB.Where("key = ?", key).First(&setting).Error; err != nil {
+		return false
+	}
+	value, _ := strconv.ParseBool(setting.Value)
+	return value
+}
+
+// twoFactorSetupRequired проверяет, что пользователь обязан настроить 2FA перед работой
+func (h *Handlers) twoFactorSetupRequired(user *models.User) bool {
+	return user.IsAdmin() && !user.TwoFactorEnabled && h.settingBool(models.SettingRequireAdmin2FA)
+}
+
+// SettingsResponse системные настройки
+type SettingsResponse struct {
+	RequireAdmin2FA bool `json:"require_admin_2fa"`
+}
+
+// SettingsRequest изменение системных настроек; отсутствующие поля не меняются
+type SettingsRequest struct {
+	RequireAdmin2FA *bool `json:"require_admin_2fa"`
+}
+
+// GetSettings возвращает системные настройки
+func (h *Handlers) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, SettingsResponse{RequireAdmin2FA: h.settingBool(models.SettingRequireAdmin2FA)})
+}
+
+// UpdateSettings изменяет системные настройки. Требование 2FA для администраторов
+// применяется к токенам, выданным после изменения (при входе или обновлении токена).
+func (h *Handlers) UpdateSettings(c *gin.Context) {
+	var req SettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RequireAdmin2FA != nil {
+		setting := models.Setting{Key: models.SettingRequireAdmin2FA, Value: strconv.FormatBool(*req.RequireAdmin2FA)}
+		if err := h.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&setting).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения настроек"})
+			return
+		}
+
+		actorID, _ := c.Get("user_id")
+		actor := actorID.(uint)
+		h.logSecurityEvent(models.SecurityEvent{
+			Type:    models.SecurityEventSettingsChanged,
+			IP:      c.ClientIP(),
+			ActorID: &actor,
+			Details: setting.Key + "=" + setting.Value,
+		})
+	}
+
+	h.GetSettings(c)
+}
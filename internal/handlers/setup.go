@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errSetupUnavailable  = errors.New("Первоначальная настройка уже выполнена")
+	errInvalidSetupToken = errors.New("Неверный токен настройки")
+	errSetupEmailTaken   = errors.New("Пользователь с таким email уже существует")
+)
+
+// SetupRequest создание первого администратора по токену первоначальной настройки
+type SetupRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"required,min=2,max=100"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=100"`
+}
+
+// Setup создает первого администратора по одноразовому токену из лога запуска
+// (или ADMIN_SETUP_TOKEN) и выполняет вход. Доступно, только пока нет ни одного администратора.
+func (h *Handlers) Setup(c *gin.Context) {
+	var req SetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := pkg.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	admin := models.User{
+		Name:     req.Name,
+		Email:    pkg.NormalizeEmail(req.Email),
+		Password: hashedPassword,
+		Role:     models.RoleAdmin,
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Блокировка строки токена: из параллельных запросов пройдет только один
+		var setting models.Setting
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", models.SettingAdminSetupTokenHash).First(&setting).Error; err != nil {
+			return errSetupUnavailable
+		}
+		if subtle.ConstantTimeCompare([]byte(setting.Value), []byte(pkg.HashToken(req.Token))) != 1 {
+			return errInvalidSetupToken
+		}
+
+		var admins int64
+		tx.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins)
+		if admins > 0 {
+			return errSetupUnavailable
+		}
+
+		var existing models.User
+		if err := tx.Unscoped().Where("LOWER(email) = ?", admin.Email).First(&existing).Error; err == nil {
+			return errSetupEmailTaken
+		}
+
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&setting).Error
+	})
+	switch {
+	case err == errSetupUnavailable:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err == errInvalidSetupToken:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err == errSetupEmailTaken:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания администратора"})
+		return
+	}
+
+	h.completeLogin(c, &admin)
+}
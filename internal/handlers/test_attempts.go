@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// attemptGracePeriod запас времени после дедлайна на сетевые задержки
+const attemptGracePeriod = 5 * time.Second
+
+var (
+	// errAttemptFinished возвращается при попытке изменить завершенную попытку
+	errAttemptFinished = errors.New("Попытка уже завершена")
+	// errAttemptInProgress возвращается, если попытка начата параллельным запросом
+	errAttemptInProgress = errors.New("Попытка уже начата, повторите запрос, чтобы продолжить ее")
+)
+
+// SaveTestAnswersRequest структура запроса сохранения ответов
+type SaveTestAnswersRequest struct {
+	Answers string `json:"answers" binding:"required"` // JSON строка с ответами {question_id: ответ}
+}
+
+// SubmitTestAttemptRequest структура запроса завершения попытки
+type SubmitTestAttemptRequest struct {
+	Answers string `json:"answers"` // Последние несохраненные ответы (необязательно)
+}
+
+// isOverdue проверяет, что срок попытки истек с учетом запаса времени
+func isOverdue(attempt *models.TestAttempt, now time.Time) bool {
+	return attempt.Deadline != nil && now.After(attempt.Deadline.Add(attemptGracePeriod))
+}
+
+// drawAttemptQuestions собирает вопросы новой попытки: вопросы теста и случайную выборку из банков.
+// Возвращает вопросы в порядке показа и перестановки вариантов {question_id: perm}.
+// test должен быть загружен с Questions и PoolRules.
+func (h *Handlers) drawAttemptQuestions(test *models.Test) ([]models.TestQuestion, map[string][]int, error) {
+	questions := append([]models.TestQuestion(nil), test.Questions...)
+	sort.SliceStable(questions, func(i, j int) bool { return questions[i].Order < questions[j].Order })
+
+	used := make(map[uint]bool, len(questions))
+	for _, q := range questions {
+		used[q.ID] = true
+	}
+
+	for _, rule := range test.PoolRules {
+		var candidates []models.TestQuestion
+		if err := h.DB.Where("pool_id = ?", rule.PoolID).Find(&candidates).Error; err != nil {
+			return nil, nil, err
+		}
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+		taken := 0
+		for _, q := range candidates {
+			if taken == rule.Count {
+				break
+			}
+			if used[q.ID] {
+				continue
+			}
+			used[q.ID] = true
+			questions = append(questions, q)
+			taken++
+		}
+	}
+
+	if test.ShuffleQuestions {
+		rand.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	}
+
+	optionOrder := make(map[string][]int)
+	if test.ShuffleOptions {
+		for i := range questions {
+			if perm := pkg.NewOptionOrder(&questions[i], test.Type); perm != nil {
+				optionOrder[strconv.Itoa(int(questions[i].ID))] = perm
+			}
+		}
+	}
+
+	return questions, optionOrder, nil
+}
+
+// displayAttemptQuestions возвращает вопросы попытки для студента: варианты в порядке показа, без ключей
+func displayAttemptQuestions(test *models.Test, questions []models.TestQuestion, optionOrder map[string][]int) []models.TestQuestionStudentView {
+	shown := make([]models.TestQuestion, 0, len(questions))
+	for _, q := range questions {
+		shown = append(shown, pkg.ApplyOptionOrder(q, test.Type, optionOrder[strconv.Itoa(int(q.ID))]))
+	}
+	return models.NewQuestionStudentViews(*test, shown, false)
+}
+
+// mergeAttemptAnswers добавляет новые ответы к сохраненным.
+// Ответы принимаются только на вопросы попытки.
+func mergeAttemptAnswers(questions []models.TestQuestion, saved, incoming string) (string, error) {
+	answers := map[string]json.RawMessage{}
+	if saved != "" {
+		if err := json.Unmarshal([]byte(saved), &answers); err != nil {
+			return "", errors.New("Сохраненные ответы повреждены")
+		}
+	}
+
+	var update map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(incoming), &update); err != nil {
+		return "", errors.New("Некорректный формат ответов")
+	}
+
+	known := make(map[string]bool, len(questions))
+	for _, q := range questions {
+		known[strconv.Itoa(int(q.ID))] = true
+	}
+
+	for questionID, answer := range update {
+		if !known[questionID] {
+			return "", errors.New("Вопрос " + questionID + " не относится к попытке")
+		}
+		answers[questionID] = answer
+	}
+
+	merged, err := json.Marshal(answers)
+	if err != nil {
+		return "", errors.New("Ошибка сохранения ответов")
+	}
+	return string(merged), nil
+}
+
+// finalizeAttempt проверяет ответы и завершает попытку с указанным статусом.
+// Завершение выполняется условным UPDATE, поэтому попытка не будет проверена дважды.
+func (h *Handlers) finalizeAttempt(attempt *models.TestAttempt, status string) error {
+	var test models.Test
+	if err := h.DB.Preload("Questions").First(&test, attempt.TestID).Error; err != nil {
+		return err
+	}
+
+	questions, optionOrder := pkg.ResolveAttemptQuestions(h.DB, attempt, &test)
+	results, rawScore := pkg.GradeAttempt(&test, questions, pkg.ParseAttemptAnswers(attempt.Answers), optionOrder)
+
+	// Опоздание считается на момент сдачи, для просроченной попытки - на момент ее срока
+	now := time.Now()
+	handedIn := now
+	if attempt.Deadline != nil && attempt.Deadline.Before(now) {
+		handedIn = *attempt.Deadline
+	}
+	window := effectiveDeadline(h.DB, models.AssignmentTest, test.ID, test.Deadline, attempt.UserID)
+	isLate, penalty := window.LatePenaltyAt(handedIn)
+	score := models.ApplyLatePenalty(rawScore, penalty)
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TestAttempt{}).
+			Where("id = ? AND status = ?", attempt.ID, models.AttemptInProgress).
+			Updates(map[string]interface{}{
+				"answers":      attempt.Answers,
+				"score":        score,
+				"raw_score":    rawScore,
+				"is_late":      isLate,
+				"late_penalty": penalty,
+				"status":       status,
+				"submitted_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAttemptFinished
+		}
+		return pkg.SaveAttemptResults(tx, attempt.ID, results)
+	})
+	if err != nil {
+		return err
+	}
+
+	attempt.Score = score
+	attempt.RawScore = rawScore
+	attempt.IsLate = isLate
+	attempt.LatePenalty = penalty
+	attempt.Status = status
+	attempt.SubmittedAt = &now
+	return nil
+}
+
+// ExpireOverdueAttempts автоматически завершает попытки с истекшим сроком
+func (h *Handlers) ExpireOverdueAttempts() {
+	var attempts []models.TestAttempt
+	h.DB.Where("status = ? AND deadline < ?", models.AttemptInProgress, time.Now().Add(-attemptGracePeriod)).Find(&attempts)
+
+	for i := range attempts {
+		if err := h.finalizeAttempt(&attempts[i], models.AttemptExpired); err != nil && !errors.Is(err, errAttemptFinished) {
+			log.Printf("Ошибка автоматического завершения попытки %d: %v", attempts[i].ID, err)
+		}
+	}
+}
+
+// StartAttemptExpiry периодически завершает просроченные попытки в фоне до отмены ctx
+func (h *Handlers) StartAttemptExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.ExpireOverdueAttempts()
+			}
+		}
+	}()
+}
+
+// retakeError отказ в новой попытке по правилам пересдачи
+type retakeError struct {
+	message string
+}
+
+func (e retakeError) Error() string {
+	return e.message
+}
+
+// lockAttempts блокирует строку пользователя до конца транзакции, чтобы параллельные запросы
+// одного студента не превысили лимит попыток и не начали две попытки одновременно
+func lockAttempts(tx *gorm.DB, userID interface{}) error {
+	var user models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error
+}
+
+// checkRetakePolicy проверяет, может ли пользователь начать новую попытку:
+// лимит попыток и минимальный интервал между ними.
+// Вызывается в транзакции после lockAttempts; отказ возвращается как retakeError.
+func checkRetakePolicy(tx *gorm.DB, test *models.Test, userID interface{}) error {
+	var attempts []models.TestAttempt
+	if err := tx.Where("user_id = ? AND test_id = ?", userID, test.ID).Order("created_at ASC").Find(&attempts).Error; err != nil {
+		return err
+	}
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	if limit := test.AttemptLimit(); limit > 0 && len(attempts) >= limit {
+		if limit == 1 {
+			return retakeError{"Тест можно пройти только один раз. Повторное прохождение запрещено администратором."}
+		}
+		return retakeError{"Использованы все попытки: " + strconv.Itoa(limit)}
+	}
+
+	if test.RetakeCooldown > 0 {
+		last := attempts[len(attempts)-1]
+		finishedAt := last.CreatedAt
+		if last.SubmittedAt != nil {
+			finishedAt = *last.SubmittedAt
+		}
+		nextAllowed := finishedAt.Add(time.Duration(test.RetakeCooldown) * time.Minute)
+		if time.Now().Before(nextAllowed) {
+			minutes := int(time.Until(nextAllowed).Minutes()) + 1
+			return retakeError{"Следующая попытка будет доступна через " + strconv.Itoa(minutes) + " мин."}
+		}
+	}
+
+	return nil
+}
+
+// loadOwnAttempt загружает попытку текущего пользователя.
+// Просроченная попытка при этом завершается автоматически.
+func (h *Handlers) loadOwnAttempt(c *gin.Context) (*models.TestAttempt, bool) {
+	// Отвечать и завершать попытку может только сам студент, без исключения для админа
+	attempt, ok := loadOwned[models.TestAttempt](c, h.DB, false, "Попытка не найдена")
+	if !ok {
+		return nil, false
+	}
+
+	if !attempt.IsFinished() && isOverdue(attempt, time.Now()) {
+		if err := h.finalizeAttempt(attempt, models.AttemptExpired); err != nil && !errors.Is(err, errAttemptFinished) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения попытки"})
+			return nil, false
+		}
+		h.DB.First(attempt, attempt.ID)
+	}
+
+	return attempt, true
+}
+
+// StartTestAttempt начинает попытку прохождения теста.
+// Если у студента уже есть незавершенная попытка, она возвращается повторно.
+func (h *Handlers) StartTestAttempt(c *gin.Context) {
+	testID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || testID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID теста"})
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var test models.Test
+	if err := h.DB.Preload("Questions").Preload("PoolRules").Scopes(visibleContent(c, models.ContentTest)).First(&test, testID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+		return
+	}
+
+	// Возвращаем незавершенную попытку, если срок еще не истек
+	var active models.TestAttempt
+	if err := h.DB.Where("user_id = ? AND test_id = ? AND status = ?", userID, testID, models.AttemptInProgress).First(&active).Error; err == nil {
+		if !isOverdue(&active, time.Now()) {
+			questions, optionOrder := pkg.ResolveAttemptQuestions(h.DB, &active, &test)
+			c.JSON(http.StatusOK, gin.H{
+				"attempt":   active,
+				"test":      models.NewTestStudentView(test, false),
+				"questions": displayAttemptQuestions(&test, questions, optionOrder),
+			})
+			return
+		}
+		if err := h.finalizeAttempt(&active, models.AttemptExpired); err != nil && !errors.Is(err, errAttemptFinished) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения попытки"})
+			return
+		}
+	}
+
+	// Проверяем окно сдачи с учетом продления
+	now := time.Now()
+	window := effectiveDeadline(h.DB, models.AssignmentTest, test.ID, test.Deadline, userID)
+	if err := window.CheckOpen(now); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Индивидуальный набор вопросов и порядок вариантов сохраняются в попытке
+	questions, optionOrder, err := h.drawAttemptQuestions(&test)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выборки вопросов"})
+		return
+	}
+	questionIDs := make([]uint, 0, len(questions))
+	for _, q := range questions {
+		questionIDs = append(questionIDs, q.ID)
+	}
+	questionIDsJSON, _ := json.Marshal(questionIDs)
+	optionOrderJSON, _ := json.Marshal(optionOrder)
+
+	attempt := models.TestAttempt{
+		UserID:      userID.(uint),
+		TestID:      uint(testID),
+		Answers:     "{}",
+		Status:      models.AttemptInProgress,
+		StartedAt:   &now,
+		QuestionIDs: string(questionIDsJSON),
+		OptionOrder: string(optionOrderJSON),
+	}
+	if test.TimeLimit > 0 {
+		deadline := now.Add(time.Duration(test.TimeLimit) * time.Minute)
+		attempt.Deadline = &deadline
+	}
+	// Попытка не может продолжаться после закрытия сдачи
+	if window.ClosesAt != nil && (attempt.Deadline == nil || window.ClosesAt.Before(*attempt.Deadline)) {
+		closesAt := *window.ClosesAt
+		attempt.Deadline = &closesAt
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockAttempts(tx, userID); err != nil {
+			return err
+		}
+		// Параллельный запрос мог начать попытку, пока мы ждали блокировку
+		var inProgress int64
+		if err := tx.Model(&models.TestAttempt{}).
+			Where("user_id = ? AND test_id = ? AND status = ?", userID, testID, models.AttemptInProgress).
+			Count(&inProgress).Error; err != nil {
+			return err
+		}
+		if inProgress > 0 {
+			return errAttemptInProgress
+		}
+		// Проверяем лимит попыток и интервал между ними
+		if err := checkRetakePolicy(tx, &test, userID); err != nil {
+			return err
+		}
+		return tx.Create(&attempt).Error
+	})
+	var denied retakeError
+	switch {
+	case errors.As(err, &denied):
+		c.JSON(http.StatusForbidden, gin.H{"error": denied.Error()})
+		return
+	case errors.Is(err, errAttemptInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка создания попытки"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"attempt":   attempt,
+		"test":      models.NewTestStudentView(test, false),
+		"questions": displayAttemptQuestions(&test, questions, optionOrder),
+	})
+}
+
+// SaveTestAttemptAnswers сохраняет ответы незавершенной попытки
+func (h *Handlers) SaveTestAttemptAnswers(c *gin.Context) {
+	attempt, ok := h.loadOwnAttempt(c)
+	if !ok {
+		return
+	}
+
+	var req SaveTestAnswersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if attempt.IsFinished() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Попытка завершена, ответы больше не принимаются"})
+		return
+	}
+
+	var test models.Test
+	if err := h.DB.Preload("Questions").First(&test, attempt.TestID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+		return
+	}
+
+	questions, _ := pkg.ResolveAttemptQuestions(h.DB, attempt, &test)
+	merged, err := mergeAttemptAnswers(questions, attempt.Answers, req.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := h.DB.Model(&models.TestAttempt{}).
+		Where("id = ? AND status = ?", attempt.ID, models.AttemptInProgress).
+		Update("answers", merged)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения ответов"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Попытка завершена, ответы больше не принимаются"})
+		return
+	}
+
+	attempt.Answers = merged
+	c.JSON(http.StatusOK, attempt)
+}
+
+// SubmitTestAttempt завершает попытку и подсчитывает баллы
+func (h *Handlers) SubmitTestAttempt(c *gin.Context) {
+	attempt, ok := h.loadOwnAttempt(c)
+	if !ok {
+		return
+	}
+
+	var req SubmitTestAttemptRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if attempt.IsFinished() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Попытка уже завершена", "attempt": attempt})
+		return
+	}
+
+	if req.Answers != "" {
+		var test models.Test
+		if err := h.DB.Preload("Questions").First(&test, attempt.TestID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+			return
+		}
+		questions, _ := pkg.ResolveAttemptQuestions(h.DB, attempt, &test)
+		merged, err := mergeAttemptAnswers(questions, attempt.Answers, req.Answers)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		attempt.Answers = merged
+	}
+
+	if err := h.finalizeAttempt(attempt, models.AttemptSubmitted); err != nil {
+		if errors.Is(err, errAttemptFinished) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения попытки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempt)
+}
+
+// buildAttemptReview собирает разбор завершенной попытки по вопросам.
+// showKey разрешает показать правильные ответы, правильность и баллы по каждому вопросу.
+func (h *Handlers) buildAttemptReview(attempt *models.TestAttempt, showKey bool) []models.AttemptReviewItem {
+	var test models.Test
+	if err := h.DB.Preload("Questions").First(&test, attempt.TestID).Error; err != nil {
+		return nil
+	}
+	test.ShowCorrectAnswers = showKey
+
+	questions, _ := pkg.ResolveAttemptQuestions(h.DB, attempt, &test)
+
+	var records []models.TestAttemptAnswer
+	h.DB.Where("attempt_id = ?", attempt.ID).Find(&records)
+	byQuestion := make(map[uint]models.TestAttemptAnswer, len(records))
+	for _, record := range records {
+		byQuestion[record.QuestionID] = record
+	}
+
+	views := models.NewQuestionStudentViews(test, questions, showKey)
+	review := make([]models.AttemptReviewItem, 0, len(questions))
+	for i, q := range questions {
+		item := models.AttemptReviewItem{
+			Position: i + 1,
+			Question: views[i],
+		}
+		if record, ok := byQuestion[q.ID]; ok {
+			if record.Answer != "" {
+				item.Answer = json.RawMessage(record.Answer)
+			}
+			if showKey {
+				isCorrect, credit := record.IsCorrect, record.Credit
+				item.IsCorrect = &isCorrect
+				item.Credit = &credit
+			}
+		}
+		review = append(review, item)
+	}
+	return review
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegradeRequest структура запроса пересчета баллов
+type RegradeRequest struct {
+	Reason string `json:"reason"` // Причина пересчета для журнала
+}
+
+// RegradeChange изменение балла попытки при пересчете
+type RegradeChange struct {
+	AttemptID uint    `json:"attempt_id"`
+	UserID    uint    `json:"user_id"`
+	OldScore  float64 `json:"old_score"`
+	NewScore  float64 `json:"new_score"`
+}
+
+// regradeTestAttempts пересчитывает завершенные попытки теста (или одну попытку, если attemptID != 0)
+// в пределах scope по текущим ключам ответов и записывает изменения баллов в журнал
+func regradeTestAttempts(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, testID, attemptID, adminID uint, reason string) ([]RegradeChange, error) {
+	var test models.Test
+	if err := tx.Preload("Questions").First(&test, testID).Error; err != nil {
+		return nil, err
+	}
+
+	query := tx.Scopes(scope).Where("test_id = ? AND status <> ?", testID, models.AttemptInProgress)
+	if attemptID != 0 {
+		query = query.Where("id = ?", attemptID)
+	}
+	var attempts []models.TestAttempt
+	if err := query.Order("id ASC").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	changes := []RegradeChange{}
+	for i := range attempts {
+		oldScore, newScore, err := pkg.RegradeAttempt(tx, &attempts[i], &test)
+		if err != nil {
+			return nil, err
+		}
+		if oldScore == newScore {
+			continue
+		}
+
+		entry := models.TestRegradeLog{
+			TestID:    testID,
+			AttemptID: attempts[i].ID,
+			AdminID:   adminID,
+			Reason:    reason,
+			OldScore:  oldScore,
+			NewScore:  newScore,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		changes = append(changes, RegradeChange{
+			AttemptID: attempts[i].ID,
+			UserID:    attempts[i].UserID,
+			OldScore:  oldScore,
+			NewScore:  newScore,
+		})
+	}
+	return changes, nil
+}
+
+// RegradeTest пересчитывает завершенные попытки теста студентов, которых проверяет пользователь
+func (h *Handlers) RegradeTest(c *gin.Context) {
+	testID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || testID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID теста"})
+		return
+	}
+
+	var test models.Test
+	if err := h.DB.First(&test, testID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+		return
+	}
+
+	h.regrade(c, test.ID, 0)
+}
+
+// RegradeTestAttempt пересчитывает одну попытку
+func (h *Handlers) RegradeTestAttempt(c *gin.Context) {
+	attemptID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || attemptID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID попытки"})
+		return
+	}
+
+	var attempt models.TestAttempt
+	if err := h.DB.Scopes(gradingScope(c, "user_id")).First(&attempt, attemptID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Попытка не найдена"})
+		return
+	}
+	if !attempt.IsFinished() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Попытка еще не завершена"})
+		return
+	}
+
+	h.regrade(c, attempt.TestID, attempt.ID)
+}
+
+// regrade выполняет пересчет в транзакции и отвечает списком изменений
+func (h *Handlers) regrade(c *gin.Context, testID, attemptID uint) {
+	var req RegradeRequest
+	// Тело запроса необязательно
+	c.ShouldBindJSON(&req)
+
+	adminID, _ := c.Get("user_id")
+	var changes []RegradeChange
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changes, err = regradeTestAttempts(tx, gradingScope(c, "user_id"), testID, attemptID, adminID.(uint), req.Reason)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка пересчета попыток"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changed": len(changes),
+		"changes": changes,
+	})
+}
+
+// GetTestRegradeLog возвращает журнал пересчетов баллов теста по попыткам студентов,
+// которых проверяет пользователь
+func (h *Handlers) GetTestRegradeLog(c *gin.Context) {
+	testID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || testID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID теста"})
+		return
+	}
+
+	var entries []models.TestRegradeLog
+	h.DB.Preload("Admin").
+		Where("test_id = ?", testID).
+		Where("attempt_id IN (?)", h.DB.Unscoped().Model(&models.TestAttempt{}).Select("id").Scopes(gradingScope(c, "user_id"))).
+		Order("created_at DESC").Find(&entries)
+	c.JSON(http.StatusOK, entries)
+}
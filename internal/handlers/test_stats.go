@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionStat статистика ответов на вопрос теста
+type QuestionStat struct {
+	QuestionID   uint    `json:"question_id"`
+	Question     string  `json:"question"`
+	Type         string  `json:"type"`
+	Answered     int64   `json:"answered"`      // Сколько раз вопрос встречался в завершенных попытках
+	Correct      int64   `json:"correct"`       // Сколько раз на него ответили полностью верно
+	MissRate     float64 `json:"miss_rate"`     // Доля неверных и пропущенных ответов (0-1)
+	AverageScore float64 `json:"average_score"` // Средняя доля балла (0-1)
+}
+
+// GetTestQuestionStats возвращает вопросы теста, отсортированные по доле ошибок,
+// по попыткам студентов, которых проверяет пользователь
+func (h *Handlers) GetTestQuestionStats(c *gin.Context) {
+	testID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || testID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID теста"})
+		return
+	}
+
+	var test models.Test
+	if err := h.DB.First(&test, testID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+		return
+	}
+
+	var rows []struct {
+		QuestionID uint
+		Answered   int64
+		Correct    int64
+		AvgCredit  float64
+	}
+	h.DB.Model(&models.TestAttemptAnswer{}).
+		Select("test_attempt_answers.question_id, COUNT(*) AS answered, "+
+			"SUM(CASE WHEN test_attempt_answers.is_correct THEN 1 ELSE 0 END) AS correct, "+
+			"AVG(test_attempt_answers.credit) AS avg_credit").
+		Joins("JOIN test_attempts ON test_attempts.id = test_attempt_answers.attempt_id AND test_attempts.deleted_at IS NULL").
+		Where("test_attempts.test_id = ? AND test_attempts.status <> ?", testID, models.AttemptInProgress).
+		Scopes(gradingScope(c, "test_attempts.user_id")).
+		Group("test_attempt_answers.question_id").
+		Scan(&rows)
+
+	questionIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		questionIDs = append(questionIDs, row.QuestionID)
+	}
+	var questions []models.TestQuestion
+	if len(questionIDs) > 0 {
+		h.DB.Unscoped().Where("id IN ?", questionIDs).Find(&questions)
+	}
+	byID := make(map[uint]models.TestQuestion, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	stats := make([]QuestionStat, 0, len(rows))
+	for _, row := range rows {
+		q := byID[row.QuestionID]
+		stat := QuestionStat{
+			QuestionID:   row.QuestionID,
+			Question:     q.Question,
+			Type:         q.EffectiveType(test.Type),
+			Answered:     row.Answered,
+			Correct:      row.Correct,
+			AverageScore: row.AvgCredit,
+		}
+		if row.Answered > 0 {
+			stat.MissRate = 1 - float64(row.Correct)/float64(row.Answered)
+		}
+		stats = append(stats, stat)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].MissRate > stats[j].MissRate })
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetTestItemAnalysis возвращает анализ качества вопросов теста: трудность, дискриминативность,
+// частоты выбора вариантов и надежность по попыткам студентов, которых проверяет пользователь.
+// ?format=csv отдает отчет файлом.
+func (h *Handlers) GetTestItemAnalysis(c *gin.Context) {
+	testID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || testID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID теста"})
+		return
+	}
+
+	var test models.Test
+	if err := h.DB.First(&test, testID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Тест не найден"})
+		return
+	}
+
+	var attempts []models.TestAttempt
+	h.DB.Preload("AnswerRecords").Scopes(gradingScope(c, "user_id")).
+		Where("test_id = ? AND status <> ?", testID, models.AttemptInProgress).
+		Order("id ASC").Find(&attempts)
+
+	// Вопросы, встречавшиеся в попытках, включая удаленные позже
+	var questions []models.TestQuestion
+	h.DB.Unscoped().
+		Where("id IN (?)", h.DB.Model(&models.TestAttemptAnswer{}).
+			Select("DISTINCT test_attempt_answers.question_id").
+			Joins("JOIN test_attempts ON test_attempts.id = test_attempt_answers.attempt_id").
+			Where("test_attempts.test_id = ?", testID).
+			Scopes(gradingScope(c, "test_attempts.user_id"))).
+		Find(&questions)
+
+	report := pkg.AnalyzeItems(&test, questions, attempts)
+
+	if c.Query("format") == "csv" {
+		writeItemAnalysisCSV(c, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// writeItemAnalysisCSV отдает анализ вопросов в CSV: строка на вопрос и сводка в конце
+func writeItemAnalysisCSV(c *gin.Context, report pkg.ItemAnalysis) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"test-%d-item-analysis.csv\"", report.TestID))
+	c.Status(http.StatusOK)
+
+	// BOM, чтобы Excel распознал UTF-8
+	c.Writer.Write([]byte("\xEF\xBB\xBF"))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"question_id", "question", "type", "responses", "omitted", "percent_correct", "difficulty", "discrimination", "options"})
+	for _, item := range report.Items {
+		options := make([]string, 0, len(item.Options))
+		for _, option := range item.Options {
+			mark := ""
+			if option.IsCorrect {
+				mark = "*"
+			}
+			options = append(options, fmt.Sprintf("%s%s: %d (%.1f%%)", mark, option.Option, option.Count, option.Rate*100))
+		}
+		w.Write([]string{
+			strconv.FormatUint(uint64(item.QuestionID), 10),
+			csvText(item.Question),
+			item.Type,
+			strconv.Itoa(item.Responses),
+			strconv.Itoa(item.Omitted),
+			formatFloat(&item.PercentCorrect),
+			formatFloat(&item.Difficulty),
+			formatFloat(item.Discrimination),
+			csvText(strings.Join(options, "; ")),
+		})
+	}
+
+	w.Write(nil)
+	w.Write([]string{"attempts", strconv.Itoa(report.Attempts)})
+	w.Write([]string{"mean_score", formatFloat(&report.MeanScore)})
+	w.Write([]string{"std_dev", formatFloat(&report.StdDev)})
+	w.Write([]string{"common_items", strconv.Itoa(report.CommonItems)})
+	w.Write([]string{"cronbach_alpha", formatFloat(report.CronbachAlpha)})
+	w.Write([]string{"kr20", formatFloat(report.KR20)})
+	w.Flush()
+}
+
+// formatFloat форматирует число для CSV; nil - пустая ячейка
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 4, 64)
+}
+
+// csvText экранирует текстовую ячейку CSV: значение, начинающееся с =, +, -, @, табуляции
+// или возврата каретки, Excel выполнил бы как формулу, поэтому к нему добавляется апостроф
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"errors"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorChallengeTTL         = 5 * time.Minute
+	twoFactorChallengeSize        = 32 // Размер токена второго шага входа в байтах
+	twoFactorChallengeMaxAttempts = 5  // Кодов на один токен второго шага, дальше - вход заново
+	recoveryCodeCount             = 10
+	recoveryCodeLength            = 10
+)
+
+var errInvalidChallenge = errors.New("Время на подтверждение входа истекло, войдите заново")
+
+// startTwoFactorChallenge выдает одноразовый токен второго шага входа
+func (h *Handlers) startTwoFactorChallenge(c *gin.Context, user *models.User) {
+	token, err := pkg.RandomToken(twoFactorChallengeSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+	challenge := models.TwoFactorChallenge{
+		UserID:    user.ID,
+		TokenHash: pkg.HashToken(token),
+		ExpiresAt: time.Now().Add(twoFactorChallengeTTL),
+	}
+	if err := h.DB.Create(&challenge).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"two_factor_required": true,
+		"challenge_token":     token,
+		"expires_in":          int(twoFactorChallengeTTL.Seconds()),
+	})
+}
+
+// checkSecondFactor проверяет код TOTP или код восстановления.
+// Принятые коды повторно не принимаются, в том числе в параллельных запросах.
+func (h *Handlers) checkSecondFactor(c *gin.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(recoveryCode))
+		result := h.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, pkg.HashToken(normalized)).
+			Update("used_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false, result.Error
+		}
+		h.logSecurityEvent(models.SecurityEvent{
+			Type:   models.SecurityEventRecoveryCode,
+			UserID: &user.ID,
+			Email:  user.Email,
+			IP:     c.ClientIP(),
+		})
+		return true, nil
+	}
+
+	step, ok := pkg.VerifyTOTP(user.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	result := h.DB.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		UpdateColumn("two_factor_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	user.TwoFactorLastStep = step
+	return true, nil
+}
+
+// generateRecoveryCodes заменяет коды восстановления пользователя новыми и возвращает их
+func generateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := pkg.RandomCode(recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: pkg.HashToken(code)}
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// disableTwoFactor отключает 2FA пользователя и удаляет коды восстановления
+func disableTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":   false,
+		"two_factor_secret":    "",
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// TwoFactorVerifyRequest второй шаг входа: код TOTP или код восстановления
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// VerifyTwoFactor завершает вход кодом TOTP или кодом восстановления
+func (h *Handlers) VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите code или recovery_code"})
+		return
+	}
+
+	var challenge models.TwoFactorChallenge
+	if err := h.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", pkg.HashToken(req.ChallengeToken), time.Now()).
+		First(&challenge).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+	var user models.User
+	if err := h.DB.First(&user, challenge.UserID).Error; err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+	if user.IsSuspended() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Учетная запись заблокирована", "code": "account_suspended"})
+		return
+	}
+
+	// Неверные коды учитываются вместе с неверными паролями
+	attempt, ok := h.reserveLoginAttempt(c, user.Email)
+	if !ok {
+		return
+	}
+	// Код учитывается в токене до проверки: после twoFactorChallengeMaxAttempts кодов
+	// токен недействителен даже при параллельных запросах
+	counted := h.DB.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND used_at IS NULL AND attempts < ?", challenge.ID, twoFactorChallengeMaxAttempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if counted.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+		return
+	}
+	if counted.RowsAffected == 0 {
+		h.releaseLoginAttempt(c, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Слишком много неверных кодов, войдите заново"})
+		return
+	}
+	ok, err := h.checkSecondFactor(c, &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email, &user, attempt)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный код подтверждения"})
+		return
+	}
+	h.releaseLoginAttempt(c, user.Email)
+
+	result := h.DB.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidChallenge.Error()})
+		return
+	}
+
+	h.completeLogin(c, &user)
+}
+
+// GetTwoFactorStatus возвращает состояние 2FA текущего пользователя
+func (h *Handlers) GetTwoFactorStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	var codesLeft int64
+	h.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&codesLeft)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":             user.TwoFactorEnabled,
+		"required":            user.IsAdmin() && h.settingBool(models.SettingRequireAdmin2FA),
+		"recovery_codes_left": codesLeft,
+	})
+}
+
+// SetupTwoFactor создает новый секрет TOTP и возвращает URI для QR-кода.
+// 2FA включается только после подтверждения кода через EnableTwoFactor.
+func (h *Handlers) SetupTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Двухфакторная аутентификация уже включена"})
+		return
+	}
+
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации секрета"})
+		return
+	}
+	if err := h.DB.Model(&user).Update("two_factor_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения секрета"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": pkg.TOTPProvisioningURI(h.Config.TOTPIssuer, user.Email, secret),
+	})
+}
+
+// TwoFactorCodeRequest код TOTP для подтверждения действия
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// EnableTwoFactor включает 2FA после проверки кода из приложения и возвращает коды восстановления
+func (h *Handlers) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Двухфакторная аутентификация уже включена"})
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Сначала получите секрет через /auth/2fa/setup"})
+		return
+	}
+
+	step, ok := pkg.VerifyTOTP(user.TwoFactorSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный код подтверждения"})
+		return
+	}
+
+	var codes []string
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled":   true,
+			"two_factor_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка включения двухфакторной аутентификации"})
+		return
+	}
+	h.Users.Invalidate(user.ID)
+	h.logSecurityEvent(models.SecurityEvent{
+		Type:   models.SecurityEventTwoFactorOn,
+		UserID: &user.ID,
+		Email:  user.Email,
+		IP:     c.ClientIP(),
+	})
+
+	// Новый access-токен текущей сессии без ограничения настройкой 2FA
+	sessionID, _ := c.Get("session_id")
+	token, err := pkg.GenerateToken(&user, sessionID.(uint), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Двухфакторная аутентификация включена. Сохраните коды восстановления: они показываются один раз",
+		"recovery_codes": codes,
+		"token":          token,
+	})
+}
+
+// DisableTwoFactorRequest отключение 2FA: пароль и код TOTP или код восстановления
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// DisableTwoFactor отключает 2FA текущего пользователя
+func (h *Handlers) DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Двухфакторная аутентификация не включена"})
+		return
+	}
+	if user.IsAdmin() && h.settingBool(models.SettingRequireAdmin2FA) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Для администраторов двухфакторная аутентификация обязательна"})
+		return
+	}
+	// Перебор пароля и кодов с украденным токеном ограничивается так же, как вход
+	attempt, ok := h.reserveLoginAttempt(c, user.Email)
+	if !ok {
+		return
+	}
+	if !pkg.CheckPassword(req.Password, user.Password) {
+		h.recordLoginFailure(c, user.Email, &user, attempt)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный пароль"})
+		return
+	}
+	ok, err := h.checkSecondFactor(c, &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email, &user, attempt)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный код подтверждения"})
+		return
+	}
+	h.releaseLoginAttempt(c, user.Email)
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return disableTwoFactor(tx, user.ID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отключения двухфакторной аутентификации"})
+		return
+	}
+	h.Users.Invalidate(user.ID)
+	h.logSecurityEvent(models.SecurityEvent{
+		Type:   models.SecurityEventTwoFactorOff,
+		UserID: &user.ID,
+		Email:  user.Email,
+		IP:     c.ClientIP(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация отключена"})
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми после проверки кода TOTP
+func (h *Handlers) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Двухфакторная аутентификация не включена"})
+		return
+	}
+	attempt, ok := h.reserveLoginAttempt(c, user.Email)
+	if !ok {
+		return
+	}
+	ok, err := h.checkSecondFactor(c, &user, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки кода"})
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email, &user, attempt)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный код подтверждения"})
+		return
+	}
+	h.releaseLoginAttempt(c, user.Email)
+
+	var codes []string
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации кодов восстановления"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor сбрасывает 2FA пользователя, потерявшего доступ к приложению и кодам (только для админа).
+// Все сессии пользователя завершаются.
+func (h *Handlers) ResetUserTwoFactor(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := disableTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeSessions(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сброса двухфакторной аутентификации"})
+		return
+	}
+	h.Users.Invalidate(user.ID)
+
+	actorID, _ := c.Get("user_id")
+	actor := actorID.(uint)
+	h.logSecurityEvent(models.SecurityEvent{
+		Type:    models.SecurityEventTwoFactorOff,
+		UserID:  &user.ID,
+		Email:   user.Email,
+		IP:      c.ClientIP(),
+		ActorID: &actor,
+		Details: "Сброшена администратором",
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация пользователя сброшена"})
+}
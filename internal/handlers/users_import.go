@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"geografi-cheb/backend/models"
+	"geografi-cheb/backend/pkg"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportFileSize  = 1 << 20 // 1 МБ
+	maxImportRows      = 500     // Пароли хешируются bcrypt в рамках запроса
+	tempPasswordLength = 10
+)
+
+// importColumns сопоставляет заголовки CSV (в нижнем регистре) с полями импорта
+var importColumns = map[string]string{
+	"name":   "name",
+	"имя":    "name",
+	"фио":    "name",
+	"email":  "email",
+	"почта":  "email",
+	"group":  "group",
+	"группа": "group",
+	"role":   "role",
+	"роль":   "role",
+}
+
+// ImportUserRow пользователь из строки CSV
+type ImportUserRow struct {
+	Row               int    `json:"row"` // Номер строки в файле (заголовок - строка 1)
+	ID                uint   `json:"id,omitempty"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	Group             string `json:"group,omitempty"`
+	Role              string `json:"role"`
+	TemporaryPassword string `json:"temporary_password,omitempty"`
+}
+
+// ImportUserError ошибка в строке CSV
+type ImportUserError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// parseImportCSV читает строки импорта; разделитель - запятая или точка с запятой
+func parseImportCSV(r io.Reader) ([]ImportUserRow, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImportFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportFileSize {
+		return nil, errors.New("Файл больше 1 МБ")
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(data) {
+		return nil, errors.New("Файл должен быть в кодировке UTF-8")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	firstLine, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n')
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.New("Ошибка чтения CSV: " + err.Error())
+	}
+	if len(records) < 2 {
+		return nil, errors.New("Файл не содержит строк с пользователями")
+	}
+	if len(records)-1 > maxImportRows {
+		return nil, errors.New("Слишком много строк, максимум " + strconv.Itoa(maxImportRows))
+	}
+
+	// Индексы колонок по заголовку
+	index := map[string]int{}
+	for i, title := range records[0] {
+		if field, ok := importColumns[strings.ToLower(strings.TrimSpace(title))]; ok {
+			index[field] = i
+		}
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, errors.New("В заголовке нет колонки name")
+	}
+	if _, ok := index["email"]; !ok {
+		return nil, errors.New("В заголовке нет колонки email")
+	}
+
+	value := func(record []string, field string) string {
+		i, ok := index[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]ImportUserRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := ImportUserRow{
+			Row:   i + 2,
+			Name:  value(record, "name"),
+			Email: pkg.NormalizeEmail(value(record, "email")),
+			Group: value(record, "group"),
+			Role:  strings.ToLower(value(record, "role")),
+		}
+		// Пропускаем пустые строки
+		if row.Name == "" && row.Email == "" && row.Group == "" && row.Role == "" {
+			continue
+		}
+		if row.Role == "" {
+			row.Role = models.RoleStudent
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// validateImportRows проверяет строки импорта и возвращает группы по названию
+func (h *Handlers) validateImportRows(rows []ImportUserRow) (map[string]*models.Group, []ImportUserError) {
+	var errs []ImportUserError
+
+	emails := make([]string, 0, len(rows))
+	for _, row := range rows {
+		emails = append(emails, row.Email)
+	}
+	var existing []string
+	h.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("LOWER(email)", &existing)
+	taken := make(map[string]bool, len(existing))
+	for _, email := range existing {
+		taken[email] = true
+	}
+
+	var groupList []models.Group
+	h.DB.Find(&groupList)
+	groups := make(map[string]*models.Group, len(groupList))
+	for i := range groupList {
+		groups[strings.ToLower(groupList[i].Name)] = &groupList[i]
+	}
+
+	seen := make(map[string]int)
+	for _, row := range rows {
+		fail := func(field, msg string) {
+			errs = append(errs, ImportUserError{Row: row.Row, Field: field, Error: msg})
+		}
+
+		if n := utf8.RuneCountInString(row.Name); n < 2 || n > 100 {
+			fail("name", "Имя должно быть от 2 до 100 символов")
+		}
+
+		if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email || len(row.Email) > 255 {
+			fail("email", "Неверный email")
+		} else if taken[row.Email] {
+			fail("email", "Пользователь с таким email уже существует")
+		} else if first, ok := seen[row.Email]; ok {
+			fail("email", "Email повторяется в строке "+strconv.Itoa(first))
+		} else {
+			seen[row.Email] = row.Row
+		}
+
+		if !models.IsValidRole(row.Role) {
+			fail("role", "Роль должна быть student, teacher или admin")
+		}
+
+		if row.Group != "" {
+			if _, ok := groups[strings.ToLower(row.Group)]; !ok {
+				fail("group", "Группа не найдена")
+			} else if row.Role == models.RoleAdmin {
+				fail("group", "Группу можно указать только студенту или преподавателю")
+			}
+		}
+	}
+
+	return groups, errs
+}
+
+// ImportUsers импортирует пользователей из CSV (колонки name, email, group, role).
+// Все строки создаются в одной транзакции: при ошибке в любой строке не создается никто.
+// Пользователи получают временные пароли, которые нужно сменить при первом входе.
+// dry_run=true только проверяет файл.
+func (h *Handlers) ImportUsers(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не найден"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка открытия файла"})
+		return
+	}
+	defer src.Close()
+
+	rows, err := parseImportCSV(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	groups, errs := h.validateImportRows(rows)
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Файл содержит ошибки, пользователи не созданы",
+			"created": 0,
+			"errors":  errs,
+		})
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"created": 0, "users": rows, "errors": []ImportUserError{}})
+		return
+	}
+
+	passwords := make([]string, len(rows))
+	for i := range rows {
+		if passwords[i], err = pkg.RandomCode(tempPasswordLength); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации пароля"})
+			return
+		}
+	}
+	// Пароли хешируем параллельно и до транзакции, чтобы не держать ее открытой
+	hashes, err := pkg.HashPasswords(passwords)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	users := make([]models.User, len(rows))
+	for i := range rows {
+		rows[i].TemporaryPassword = passwords[i]
+		users[i] = models.User{
+			Name:               rows[i].Name,
+			Email:              rows[i].Email,
+			Password:           hashes[i],
+			Role:               rows[i].Role,
+			MustChangePassword: true,
+		}
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range users {
+			if err := tx.Create(&users[i]).Error; err != nil {
+				return err
+			}
+			rows[i].ID = users[i].ID
+
+			if rows[i].Group == "" {
+				continue
+			}
+			association := "Members"
+			if users[i].Role == models.RoleTeacher {
+				association = "Teachers"
+			}
+			if err := tx.Model(groups[strings.ToLower(rows[i].Group)]).Association(association).Append(&users[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка импорта пользователей, изменения отменены"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"created": len(users), "users": rows, "errors": []ImportUserError{}})
+}
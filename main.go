@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"geografi-cheb/backend/api"
 	"geografi-cheb/backend/config"
 	"geografi-cheb/backend/db"
 	_ "geografi-cheb/backend/docs" // Swagger документация
+	"geografi-cheb/backend/internal/handlers"
 	"geografi-cheb/backend/pkg"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,9 +38,15 @@ import (
 // @name Authorization
 // @description JWT токен авторизации. Формат: "Bearer {token}"
 
+// shutdownTimeout - время на завершение текущих запросов при остановке сервера
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Загрузка конфигурации
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Ошибка конфигурации: %v", err)
+	}
 
 	// Инициализация базы данных
 	database, err := db.Init(cfg.DatabaseURL)
@@ -44,12 +58,40 @@ func main() {
 	if err := db.RunMigrations(database); err != nil {
 		log.Fatalf("Ошибка миграций: %v", err)
 	}
+	// Перенос ответов старых попыток в test_attempt_answers
+	if err := db.RunOnce(database, "attempt_answers", pkg.BackfillAttemptAnswers); err != nil {
+		log.Fatalf("Ошибка миграций: %v", err)
+	}
 
-	// Создание администратора по умолчанию
-	pkg.InitAdmin(database)
+	// Создание первого администратора
+	if err := pkg.InitAdmin(database, pkg.AdminBootstrap{
+		Name:       cfg.AdminName,
+		Email:      cfg.AdminEmail,
+		Password:   cfg.AdminPassword,
+		SetupToken: cfg.AdminSetupToken,
+	}); err != nil {
+		log.Fatalf("Ошибка создания администратора: %v", err)
+	}
+
+	// Администраторы, созданные ранее с паролем по умолчанию, должны его сменить
+	expired, err := pkg.ExpireAdminPassword(database, config.DefaultAdminPassword)
+	if err != nil {
+		log.Fatalf("Ошибка проверки паролей администраторов: %v", err)
+	}
+	if expired > 0 {
+		if cfg.IsProduction() {
+			log.Fatalf("Администраторов с паролем по умолчанию: %d. Смените пароль (например, запустив сервер с ENVIRONMENT=development) перед запуском в production", expired)
+		}
+		log.Printf("Warning: администраторов с паролем по умолчанию: %d, при входе потребуется смена пароля", expired)
+	}
 
 	// Настройка роутера
 	router := gin.Default()
+	// IP клиента (лимит попыток входа, журнал безопасности) берется из X-Forwarded-For
+	// только от доверенных прокси, иначе заголовок подделывается клиентом
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Ошибка TRUSTED_PROXIES: %v", err)
+	}
 
 	// Простой глобальный CORS: разрешаем доступ со всех доменов
 	router.Use(func(c *gin.Context) {
@@ -70,13 +112,36 @@ func main() {
 	router.Static("/uploads", cfg.UploadDir)
 
 	// Инициализация API
-	api.SetupRoutes(router, database, cfg)
+	h, err := handlers.NewHandlers(database, cfg)
+	if err != nil {
+		log.Fatalf("Ошибка инициализации API: %v", err)
+	}
+	api.SetupRoutes(router, h)
+
+	// Фоновые задачи работают до сигнала остановки
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Автоматическое завершение попыток с истекшим временем
+	h.StartAttemptExpiry(ctx, time.Minute)
+	// Удаление истекших сессий и счетчиков попыток входа
+	h.StartAuthCleanup(ctx, time.Hour)
 
 	// Запуск сервера
 	// Слушаем на всех интерфейсах для работы в Docker/контейнере
 	addr := "0.0.0.0:" + cfg.Port
-	log.Printf("Сервер запущен на %s", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Ошибка запуска сервера: %v", err)
+	server := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		log.Printf("Сервер запущен на %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Ошибка запуска сервера: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Остановка сервера")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Ошибка остановки сервера: %v", err)
 	}
 }
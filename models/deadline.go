@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ошибки окна сдачи задания
+var (
+	ErrAssignmentNotOpen = errors.New("Задание еще не открыто для сдачи")
+	ErrAssignmentClosed  = errors.New("Срок сдачи задания истек")
+)
+
+// Типы заданий для продления сроков
+const (
+	AssignmentTest     = "test"
+	AssignmentPractice = "practice"
+)
+
+// Deadline описывает окно сдачи задания и штраф за опоздание.
+// Встраивается в Test и Practice.
+type Deadline struct {
+	OpensAt     *time.Time `json:"opens_at"`                      // До этого момента сдача недоступна
+	DueAt       *time.Time `json:"due_at"`                        // Срок сдачи, после него работа считается опоздавшей
+	ClosesAt    *time.Time `json:"closes_at"`                     // После этого момента сдача недоступна (nil - без ограничения)
+	LatePenalty float64    `json:"late_penalty" gorm:"default:0"` // Штраф в процентах за каждые начатые сутки опоздания
+}
+
+// DeadlineExtension представляет индивидуальное продление срока для студента
+type DeadlineExtension struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	AssignmentType string         `json:"assignment_type" gorm:"not null;uniqueIndex:idx_extension_assignment_user"` // test или practice
+	AssignmentID   uint           `json:"assignment_id" gorm:"not null;uniqueIndex:idx_extension_assignment_user"`
+	UserID         uint           `json:"user_id" gorm:"not null;uniqueIndex:idx_extension_assignment_user"`
+	DueAt          *time.Time     `json:"due_at"`    // Новый срок сдачи
+	ClosesAt       *time.Time     `json:"closes_at"` // Новое закрытие сдачи
+	Reason         string         `json:"reason" gorm:"type:text"`
+	GrantedBy      uint           `json:"granted_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Effective возвращает окно сдачи с учетом продления (ext может быть nil)
+func (d Deadline) Effective(ext *DeadlineExtension) Deadline {
+	if ext == nil {
+		return d
+	}
+	if ext.DueAt != nil {
+		d.DueAt = ext.DueAt
+	}
+	if ext.ClosesAt != nil {
+		d.ClosesAt = ext.ClosesAt
+	}
+	return d
+}
+
+// CheckOpen проверяет, что в момент now задание можно сдавать
+func (d Deadline) CheckOpen(now time.Time) error {
+	if d.OpensAt != nil && now.Before(*d.OpensAt) {
+		return ErrAssignmentNotOpen
+	}
+	if d.ClosesAt != nil && now.After(*d.ClosesAt) {
+		return ErrAssignmentClosed
+	}
+	return nil
+}
+
+// LatePenaltyAt возвращает признак опоздания и штраф в процентах для работы, сданной в момент at
+func (d Deadline) LatePenaltyAt(at time.Time) (bool, float64) {
+	if d.DueAt == nil || !at.After(*d.DueAt) {
+		return false, 0
+	}
+	days := math.Ceil(at.Sub(*d.DueAt).Hours() / 24)
+	return true, math.Min(100, days*d.LatePenalty)
+}
+
+// ApplyLatePenalty уменьшает балл на штраф в процентах
+func ApplyLatePenalty(score, penalty float64) float64 {
+	if penalty <= 0 {
+		return score
+	}
+	return score * (1 - math.Min(100, penalty)/100)
+}
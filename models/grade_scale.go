@@ -0,0 +1,34 @@
+package models
+
+import "errors"
+
+// Шкалы оценок преподавателя за тесты и практики
+const (
+	GradeScaleFive    = "five"    // Пятибалльная оценка (1-5)
+	GradeScalePercent = "percent" // Проценты (0-100)
+)
+
+// Ошибки проверки оценки по шкале
+var (
+	ErrInvalidGradeScale = errors.New("Шкала оценки должна быть five или percent")
+	ErrGradeOutOfScale   = errors.New("Оценка вне шкалы: для five - от 1 до 5, для percent - от 0 до 100")
+)
+
+// CheckGradeScale проверяет, что оценка входит в шкалу, и возвращает шкалу.
+// Пустая шкала считается пятибалльной.
+func CheckGradeScale(grade float64, scale string) (string, error) {
+	switch scale {
+	case "", GradeScaleFive:
+		if grade < 1 || grade > 5 {
+			return "", ErrGradeOutOfScale
+		}
+		return GradeScaleFive, nil
+	case GradeScalePercent:
+		if grade < 0 || grade > 100 {
+			return "", ErrGradeOutOfScale
+		}
+		return GradeScalePercent, nil
+	default:
+		return "", ErrInvalidGradeScale
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GradeWeights задает веса категорий работ в среднем балле журнала.
+// Встраивается в Group.
+type GradeWeights struct {
+	TestWeight     float64 `json:"test_weight" gorm:"default:1"`
+	PracticeWeight float64 `json:"practice_weight" gorm:"default:1"`
+}
+
+// DefaultGradeWeights возвращает равные веса категорий
+func DefaultGradeWeights() GradeWeights {
+	return GradeWeights{TestWeight: 1, PracticeWeight: 1}
+}
+
+// Group представляет учебную группу
+type Group struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"not null;uniqueIndex"`
+	Description  string         `json:"description" gorm:"type:text"`
+	JoinCode     *string        `json:"join_code,omitempty" gorm:"uniqueIndex"` // Код для самостоятельного вступления
+	GradeWeights                // Веса категорий в журнале
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	Teachers []User         `json:"teachers,omitempty" gorm:"many2many:group_teachers"`
+	Members  []User         `json:"members,omitempty" gorm:"many2many:group_members"`
+	Content  []GroupContent `json:"content,omitempty" gorm:"foreignKey:GroupID"`
+}
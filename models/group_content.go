@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Типы материалов, назначаемых группам
+const (
+	ContentLesson   = "lesson"
+	ContentTest     = "test"
+	ContentPractice = "practice"
+)
+
+// IsValidContentType проверяет тип назначаемого материала
+func IsValidContentType(contentType string) bool {
+	switch contentType {
+	case ContentLesson, ContentTest, ContentPractice:
+		return true
+	}
+	return false
+}
+
+// GroupContent назначает урок, тест или практику группе.
+// Материал без назначений доступен всем студентам, назначенный - только студентам
+// этих групп начиная с PublishAt. Назначение преподавателя (TeacherID) скрывает
+// материал только в группах этого преподавателя, назначение администратора - во всех.
+// Назначение урока распространяется на его тесты и практики.
+type GroupContent struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	GroupID     uint       `json:"group_id" gorm:"not null;uniqueIndex:idx_group_content"`
+	ContentType string     `json:"content_type" gorm:"not null;uniqueIndex:idx_group_content;index:idx_group_content_item"` // lesson, test или practice
+	ContentID   uint       `json:"content_id" gorm:"not null;uniqueIndex:idx_group_content;index:idx_group_content_item"`
+	PublishAt   *time.Time `json:"publish_at"` // nil - опубликовано сразу
+	TeacherID   *uint      `json:"teacher_id"` // Назначил преподаватель; nil - администратор
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Связи
+	Group Group `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginThrottle счетчик неудачных попыток входа по ключу (аккаунт или IP)
+// для хранилища ограничителя в PostgreSQL
+type LoginThrottle struct {
+	Key          string     `gorm:"primaryKey;size:320"`
+	Failures     int        `gorm:"not null;default:0"`
+	WindowEndsAt time.Time  `gorm:"not null"` // Конец окна подсчета неудач
+	BlockedUntil *time.Time // Попытки запрещены до этого времени
+	UpdatedAt    time.Time
+}
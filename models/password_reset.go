@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PasswordResetToken одноразовый токен восстановления пароля.
+// Хранится только SHA-256 токена, сам токен отправляется пользователю в письме.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"` // Использован или отозван
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+// Роли пользователей
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
+
+// Права доступа
+const (
+	PermManageUsers    = "users.manage"    // Управление пользователями
+	PermManageSettings = "settings.manage" // Системные настройки
+	PermManageGroups   = "groups.manage"   // Создание групп, назначение преподавателей и студентов
+	PermManageContent  = "content.manage"  // Уроки, тесты, банки вопросов, практики, факты, видео
+	PermGrade          = "grades.manage"   // Проверка работ и оценки студентов своих групп
+	PermGradeAll       = "grades.all"      // Проверка работ и оценки всех студентов
+)
+
+// rolePermissions права каждой роли
+var rolePermissions = map[string][]string{
+	RoleStudent: {},
+	RoleTeacher: {PermManageContent, PermGrade},
+	RoleAdmin:   {PermManageUsers, PermManageSettings, PermManageGroups, PermManageContent, PermGrade, PermGradeAll},
+}
+
+// IsValidRole проверяет роль пользователя
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission проверяет, есть ли у роли право
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
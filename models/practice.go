@@ -12,6 +12,7 @@ type Practice struct {
 	LessonID  uint           `json:"lesson_id" gorm:"not null;index"`
 	Title     string         `json:"title" gorm:"not null"`
 	FileURL   string         `json:"file_url"` // URL файла задания
+	Deadline                 // Окно сдачи и штраф за опоздание
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -28,6 +29,11 @@ type PracticeSubmit struct {
 	UserID    uint           `json:"user_id" gorm:"not null;index"`
 	PracticeID uint          `json:"practice_id" gorm:"not null;index"`
 	FileURL   string         `json:"file_url" gorm:"not null"` // URL загруженного файла
+	Comment   string         `json:"comment" gorm:"type:text"` // Комментарий студента к версии
+	Version   int            `json:"version" gorm:"not null;default:1"` // Номер версии в рамках задания (с 1)
+	Status    string         `json:"status" gorm:"not null;default:'submitted';index"` // submitted, under_review, returned, accepted
+	IsLate    bool           `json:"is_late" gorm:"default:false"` // Сдано после срока (для новых версий берется из первой)
+	LatePenalty float64      `json:"late_penalty" gorm:"default:0"` // Штраф в процентах
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -35,6 +41,53 @@ type PracticeSubmit struct {
 	// Связи
 	User     User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Practice Practice `json:"practice,omitempty" gorm:"foreignKey:PracticeID"`
+	Comments []PracticeSubmitComment `json:"comments,omitempty" gorm:"foreignKey:SubmitID"`
+}
+
+// Статусы отправки практического задания
+const (
+	SubmitSubmitted   = "submitted"    // Отправлено, ждет проверки
+	SubmitUnderReview = "under_review" // На проверке
+	SubmitReturned    = "returned"     // Возвращено на доработку
+	SubmitAccepted    = "accepted"     // Принято
+)
+
+// IsValidSubmitStatus проверяет статус отправки
+func IsValidSubmitStatus(status string) bool {
+	switch status {
+	case SubmitSubmitted, SubmitUnderReview, SubmitReturned, SubmitAccepted:
+		return true
+	}
+	return false
+}
+
+// CanResubmit сообщает, можно ли отправить новую версию после этой
+func (s *PracticeSubmit) CanResubmit() bool {
+	return s.Status == SubmitReturned
+}
+
+// PracticeSubmitComment представляет комментарий преподавателя к версии отправки
+type PracticeSubmitComment struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	SubmitID  uint           `json:"submit_id" gorm:"not null;index"`
+	AuthorID  uint           `json:"author_id" gorm:"not null"`
+	Text      string         `json:"text" gorm:"type:text;not null"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	Author User `json:"author,omitempty" gorm:"foreignKey:AuthorID"`
+}
+
+// PracticeSubmitThread представляет все версии отправки студента по одному заданию
+type PracticeSubmitThread struct {
+	Practice Practice         `json:"practice"`
+	Status   string           `json:"status"`  // Статус последней версии
+	Version  int              `json:"version"` // Номер последней версии
+	Current  PracticeSubmit   `json:"current"`
+	Versions []PracticeSubmit `json:"versions"` // Все версии, от новой к старой
+	Grade    *PracticeGrade   `json:"grade,omitempty"`
 }
 
 // PracticeGrade представляет оценку практического задания, выставленную администратором
@@ -43,7 +96,10 @@ type PracticeGrade struct {
 	UserID      uint           `json:"user_id" gorm:"not null;index"`
 	PracticeID  uint           `json:"practice_id" gorm:"not null;index"`
 	SubmitID    uint           `json:"submit_id" gorm:"index"` // Связь с отправкой
-	Grade       float64        `json:"grade" gorm:"not null"`  // Оценка от преподавателя
+	Grade       float64        `json:"grade" gorm:"not null"`  // Оценка с учетом штрафа за опоздание
+	RawGrade    float64        `json:"raw_grade"`              // Оценка от преподавателя до штрафа
+	LatePenalty float64        `json:"late_penalty" gorm:"default:0"` // Примененный штраф в процентах
+	Scale       string         `json:"scale" gorm:"size:16"`          // Шкала оценки: five или percent
 	Comment     string         `json:"comment" gorm:"type:text"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuestionPool представляет банк вопросов по уроку и теме
+type QuestionPool struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	LessonID  uint           `json:"lesson_id" gorm:"not null;index"`
+	Topic     string         `json:"topic" gorm:"index"`
+	Title     string         `json:"title" gorm:"not null"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	Lesson    Lesson         `json:"lesson,omitempty" gorm:"foreignKey:LessonID"`
+	Questions []TestQuestion `json:"questions,omitempty" gorm:"foreignKey:PoolID"`
+}
+
+// TestPoolRule представляет правило выборки: взять Count случайных вопросов из банка PoolID
+type TestPoolRule struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	TestID    uint           `json:"test_id" gorm:"not null;index"`
+	PoolID    uint           `json:"pool_id" gorm:"not null;index"`
+	Count     int            `json:"count" gorm:"not null"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	Pool QuestionPool `json:"pool,omitempty" gorm:"foreignKey:PoolID"`
+}
@@ -8,17 +8,36 @@ import (
 
 // Report представляет доклад (файл, загруженный пользователем)
 type Report struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"user_id" gorm:"not null;index"`
+	LessonID    uint           `json:"lesson_id" gorm:"index"`
+	Title       string         `json:"title" gorm:"not null"`
+	Description string         `json:"description" gorm:"type:text"`
+	FileURL     string         `json:"file_url" gorm:"not null"`
+	Feedback    string         `json:"feedback" gorm:"type:text"` // Отзыв преподавателя
+	FeedbackAt  *time.Time     `json:"feedback_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	User   User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Lesson Lesson       `json:"lesson,omitempty" gorm:"foreignKey:LessonID"`
+	Grade  *ReportGrade `json:"grade,omitempty" gorm:"foreignKey:ReportID"`
+}
+
+// ReportGrade представляет оценку доклада, выставленную администратором
+type ReportGrade struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	UserID    uint           `json:"user_id" gorm:"not null;index"`
-	LessonID  uint           `json:"lesson_id" gorm:"index"`
-	Title     string         `json:"title" gorm:"not null"`
-	FileURL   string         `json:"file_url" gorm:"not null"`
+	ReportID  uint           `json:"report_id" gorm:"not null;index"`
+	Grade     float64        `json:"grade" gorm:"not null"` // Оценка от преподавателя
+	Comment   string         `json:"comment" gorm:"type:text"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Связи
 	User   User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Lesson Lesson `json:"lesson,omitempty" gorm:"foreignKey:LessonID"`
+	Report Report `json:"report,omitempty" gorm:"foreignKey:ReportID"`
 }
-
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Типы событий безопасности
+const (
+	SecurityEventAccountLocked   = "account_locked"   // Аккаунт заблокирован после неудачных попыток входа
+	SecurityEventIPLocked        = "ip_locked"        // IP заблокирован после неудачных попыток входа
+	SecurityEventAccountUnlocked = "account_unlocked" // Администратор снял блокировку входа
+	SecurityEventTwoFactorOn     = "two_factor_enabled"
+	SecurityEventTwoFactorOff    = "two_factor_disabled" // Отключена пользователем или сброшена администратором
+	SecurityEventRecoveryCode    = "recovery_code_used"
+	SecurityEventSettingsChanged = "settings_changed"
+)
+
+// SecurityEvent запись журнала безопасности
+type SecurityEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"not null;index"`
+	UserID    *uint     `json:"user_id" gorm:"index"` // Пользователь, к которому относится событие
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	ActorID   *uint     `json:"actor_id"` // Администратор, выполнивший действие
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
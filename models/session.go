@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session сессия входа с ротируемым refresh-токеном.
+// Хранится только SHA-256 токенов; при обновлении текущий токен заменяется новым,
+// а повторное использование замененного токена отзывает сессию.
+type Session struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	RefreshHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	PreviousHash string     `json:"-" gorm:"index"` // Замененный токен для обнаружения повторного использования
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	UserAgent    string     `json:"user_agent"`
+	IP           string     `json:"ip"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// IsActive проверяет, что сессия не отозвана и не истекла
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
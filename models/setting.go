@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Ключи системных настроек
+const (
+	SettingRequireAdmin2FA     = "require_admin_2fa"      // Администраторы обязаны использовать двухфакторную аутентификацию
+	SettingAdminSetupTokenHash = "admin_setup_token_hash" // SHA-256 токена первоначальной настройки, пока нет администратора
+	SettingMigrationPrefix     = "migration:"             // Префикс отметок о выполненных разовых миграциях данных
+)
+
+// Setting системная настройка (ключ - значение)
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -13,7 +13,16 @@ type Test struct {
 	Title     string         `json:"title" gorm:"not null"`
 	Description string       `json:"description" gorm:"type:text"` // Описание теста
 	Type      string         `json:"type" gorm:"default:'single'"` // Тип: single (один правильный), multiple (несколько правильных)
+	ScoringMode string       `json:"scoring_mode" gorm:"default:'all_or_nothing'"` // Подсчет для multiple: all_or_nothing или partial (частичный балл со штрафом за неверные)
 	AllowRetake bool         `json:"allow_retake" gorm:"default:false"` // Разрешить повторное прохождение
+	MaxAttempts int          `json:"max_attempts" gorm:"default:0"` // Максимум попыток при разрешенной пересдаче (0 - без ограничения)
+	RetakeCooldown int       `json:"retake_cooldown" gorm:"default:0"` // Минимальный интервал между попытками в минутах
+	ScorePolicy string       `json:"score_policy" gorm:"default:'best'"` // Какой балл идет в зачет: best, last, average, first
+	ShowCorrectAnswers bool  `json:"show_correct_answers" gorm:"default:false"` // Показывать правильные ответы студенту, когда пересдать тест уже нельзя
+	TimeLimit int            `json:"time_limit" gorm:"default:0"` // Ограничение времени в минутах (0 - без ограничения)
+	ShuffleQuestions bool    `json:"shuffle_questions" gorm:"default:false"` // Перемешивать порядок вопросов для каждой попытки
+	ShuffleOptions bool      `json:"shuffle_options" gorm:"default:false"` // Перемешивать варианты ответов для каждой попытки
+	Deadline                 // Окно сдачи и штраф за опоздание
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -21,17 +30,102 @@ type Test struct {
 	// Связи
 	Lesson   Lesson         `json:"lesson,omitempty" gorm:"foreignKey:LessonID"`
 	Questions []TestQuestion `json:"questions,omitempty" gorm:"foreignKey:TestID;order:order"`
+	PoolRules []TestPoolRule `json:"pool_rules,omitempty" gorm:"foreignKey:TestID"`
 	Attempts []TestAttempt   `json:"attempts,omitempty" gorm:"foreignKey:TestID"`
 	Grades   []TestGrade     `json:"grades,omitempty" gorm:"foreignKey:TestID"`
 }
 
+// Правила выбора итогового балла при нескольких попытках
+const (
+	ScorePolicyBest    = "best"    // Лучшая попытка
+	ScorePolicyLast    = "last"    // Последняя попытка
+	ScorePolicyAverage = "average" // Среднее по всем попыткам
+	ScorePolicyFirst   = "first"   // Первая попытка
+)
+
+// IsValidScorePolicy проверяет правило выбора итогового балла
+func IsValidScorePolicy(policy string) bool {
+	switch policy {
+	case ScorePolicyBest, ScorePolicyLast, ScorePolicyAverage, ScorePolicyFirst:
+		return true
+	}
+	return false
+}
+
+// RequiresSession проверяет, что тест проходится только через начало попытки:
+// ограничение времени или индивидуальный набор вопросов для каждого студента.
+// PoolRules должны быть загружены.
+func (t *Test) RequiresSession() bool {
+	return t.TimeLimit > 0 || t.ShuffleQuestions || t.ShuffleOptions || len(t.PoolRules) > 0
+}
+
+// AttemptLimit возвращает максимальное число попыток (0 - без ограничения)
+func (t *Test) AttemptLimit() int {
+	if !t.AllowRetake {
+		return 1
+	}
+	return t.MaxAttempts
+}
+
+// EffectiveScore возвращает итоговый балл по правилу теста.
+// attempts должны быть отсортированы по времени создания, незавершенные попытки не учитываются.
+// Второе значение false, если завершенных попыток нет.
+func (t *Test) EffectiveScore(attempts []TestAttempt) (float64, bool) {
+	var scores []float64
+	for i := range attempts {
+		if attempts[i].IsFinished() {
+			scores = append(scores, attempts[i].Score)
+		}
+	}
+	if len(scores) == 0 {
+		return 0, false
+	}
+
+	switch t.ScorePolicy {
+	case ScorePolicyLast:
+		return scores[len(scores)-1], true
+	case ScorePolicyFirst:
+		return scores[0], true
+	case ScorePolicyAverage:
+		sum := 0.0
+		for _, score := range scores {
+			sum += score
+		}
+		return sum / float64(len(scores)), true
+	default:
+		best := scores[0]
+		for _, score := range scores[1:] {
+			if score > best {
+				best = score
+			}
+		}
+		return best, true
+	}
+}
+
+// Статусы попытки прохождения теста
+const (
+	AttemptInProgress = "in_progress" // Тест начат, ответы сохраняются
+	AttemptSubmitted  = "submitted"   // Попытка отправлена студентом и проверена
+	AttemptExpired    = "expired"     // Время вышло, попытка отправлена автоматически
+)
+
 // TestAttempt представляет попытку прохождения теста пользователем
 type TestAttempt struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	UserID    uint           `json:"user_id" gorm:"not null;index"`
 	TestID    uint           `json:"test_id" gorm:"not null;index"`
 	Answers   string         `json:"answers" gorm:"type:text"` // JSON строка с ответами пользователя {question_id: answer_index}
-	Score     float64        `json:"score"`                    // Автоматически подсчитанный балл (0-100)
+	Score     float64        `json:"score"`                    // Итоговый балл с учетом штрафа за опоздание (0-100)
+	RawScore  float64        `json:"raw_score"`                // Балл по ответам до штрафа
+	IsLate    bool           `json:"is_late" gorm:"default:false"`
+	LatePenalty float64      `json:"late_penalty" gorm:"default:0"` // Примененный штраф в процентах
+	Status    string         `json:"status" gorm:"default:'submitted';index"` // in_progress, submitted, expired
+	StartedAt *time.Time     `json:"started_at"`
+	Deadline  *time.Time     `json:"deadline"` // Серверный срок сдачи (nil - без ограничения)
+	SubmittedAt *time.Time   `json:"submitted_at"`
+	QuestionIDs string       `json:"question_ids" gorm:"type:text"` // JSON массив ID вопросов попытки в порядке показа (пусто - вопросы теста)
+	OptionOrder string       `json:"option_order" gorm:"type:text"` // JSON {question_id: [исходный индекс для каждой показанной позиции]}
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -39,6 +133,15 @@ type TestAttempt struct {
 	// Связи
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Test Test `json:"test,omitempty" gorm:"foreignKey:TestID"`
+	AnswerRecords []TestAttemptAnswer `json:"-" gorm:"foreignKey:AttemptID"`
+
+	// Подробный разбор по вопросам (заполняется при просмотре попытки)
+	Review []AttemptReviewItem `json:"review,omitempty" gorm:"-"`
+}
+
+// IsFinished проверяет, что попытка завершена и проверена
+func (a *TestAttempt) IsFinished() bool {
+	return a.Status != AttemptInProgress
 }
 
 // TestGrade представляет оценку теста, выставленную администратором
@@ -48,11 +151,16 @@ type TestGrade struct {
 	TestID    uint           `json:"test_id" gorm:"not null;index"`
 	AttemptID uint           `json:"attempt_id" gorm:"index"` // Связь с попыткой
 	Grade     float64        `json:"grade" gorm:"not null"`   // Оценка от преподавателя
+	Scale     string         `json:"scale" gorm:"size:16"`    // Шкала оценки: five или percent
 	Comment   string         `json:"comment" gorm:"type:text"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// Вычисляемые поля (не хранятся в БД)
+	EffectiveScore *float64 `json:"effective_score,omitempty" gorm:"-"` // Итоговый автоматический балл по правилу теста
+	AttemptsCount  int      `json:"attempts_count" gorm:"-"`
+
 	// Связи
 	User    User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Test    Test `json:"test,omitempty" gorm:"foreignKey:TestID"`
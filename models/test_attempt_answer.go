@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TestAttemptAnswer представляет проверенный ответ на один вопрос попытки
+type TestAttemptAnswer struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	AttemptID  uint           `json:"attempt_id" gorm:"not null;index"`
+	QuestionID uint           `json:"question_id" gorm:"not null;index"`
+	Position   int            `json:"position"`                // Позиция вопроса в попытке (с 1)
+	Answer     string         `json:"answer" gorm:"type:text"` // JSON ответа в исходных индексах вариантов (пусто - нет ответа)
+	IsCorrect  bool           `json:"is_correct"`              // Ответ полностью верный
+	Credit     float64        `json:"credit"`                  // Доля балла от 0 до 1
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Связи
+	Question TestQuestion `json:"-" gorm:"foreignKey:QuestionID"`
+}
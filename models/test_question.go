@@ -6,13 +6,29 @@ import (
 	"gorm.io/gorm"
 )
 
+// Типы вопросов теста
+const (
+	QuestionSingle   = "single"   // Один правильный вариант
+	QuestionMultiple = "multiple" // Несколько правильных вариантов
+	QuestionNumeric  = "numeric"  // Числовой ответ с допуском
+	QuestionText     = "text"     // Короткий текстовый ответ с синонимами
+	QuestionMatching = "matching" // Сопоставление (страна → столица)
+	QuestionOrdering = "ordering" // Упорядочивание (реки по длине)
+	QuestionMap      = "map"      // Точка на карте (GeoJSON)
+)
+
 // TestQuestion представляет вопрос в тесте
 type TestQuestion struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
-	TestID    uint           `json:"test_id" gorm:"not null;index"`
+	TestID    *uint          `json:"test_id" gorm:"index"` // Тест, к которому относится вопрос (nil - вопрос из банка)
+	PoolID    *uint          `json:"pool_id" gorm:"index"` // Банк вопросов (nil - вопрос теста)
+	Type      string         `json:"type"` // Тип вопроса; пустой - совпадает с типом теста
 	Question  string         `json:"question" gorm:"not null;type:text"`
-	Options   string         `json:"options" gorm:"type:text;not null"` // JSON массив вариантов ответов
+	Options   string         `json:"options" gorm:"type:text;not null"` // JSON массив вариантов ответов (для matching - левый столбец)
+	MatchOptions string      `json:"match_options" gorm:"type:text"` // JSON массив правого столбца для matching
 	CorrectAnswer int        `json:"correct_answer" gorm:"not null"` // Индекс правильного ответа (0-based)
+	CorrectAnswers string    `json:"correct_answers" gorm:"type:text"` // JSON массив индексов правильных ответов (для multiple)
+	AnswerKey string         `json:"answer_key" gorm:"type:text"` // JSON ключ ответа для numeric, text, matching, ordering, map (GeoJSON)
 	Order     int            `json:"order" gorm:"default:0"` // Порядок вопроса в тесте
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -20,5 +36,16 @@ type TestQuestion struct {
 
 	// Связи
 	Test Test `json:"test,omitempty" gorm:"foreignKey:TestID"`
+	Pool *QuestionPool `json:"pool,omitempty" gorm:"foreignKey:PoolID"`
 }
 
+// EffectiveType возвращает тип вопроса с учетом типа теста по умолчанию
+func (q *TestQuestion) EffectiveType(testType string) string {
+	if q.Type != "" {
+		return q.Type
+	}
+	if testType == QuestionMultiple {
+		return QuestionMultiple
+	}
+	return QuestionSingle
+}
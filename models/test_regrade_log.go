@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// TestRegradeLog представляет запись журнала пересчета баллов попытки
+type TestRegradeLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TestID    uint      `json:"test_id" gorm:"not null;index"`
+	AttemptID uint      `json:"attempt_id" gorm:"not null;index"`
+	AdminID   uint      `json:"admin_id" gorm:"not null"`
+	Reason    string    `json:"reason" gorm:"type:text"`
+	OldScore  float64   `json:"old_score"`
+	NewScore  float64   `json:"new_score"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Связи
+	Admin   User        `json:"admin,omitempty" gorm:"foreignKey:AdminID"`
+	Attempt TestAttempt `json:"-" gorm:"foreignKey:AttemptID"`
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TestQuestionStudentView представляет вопрос теста в том виде, в котором его видит студент.
+// Ключ ответа попадает сюда только после проверенной попытки и только если тест это разрешает.
+type TestQuestionStudentView struct {
+	ID             uint   `json:"id"`
+	TestID         uint   `json:"test_id"`
+	Type           string `json:"type"`
+	Question       string `json:"question"`
+	Options        string `json:"options"` // JSON массив вариантов ответов
+	MatchOptions   string `json:"match_options,omitempty"`
+	Order          int    `json:"order"`
+	CorrectAnswer  *int   `json:"correct_answer,omitempty"`
+	CorrectAnswers string `json:"correct_answers,omitempty"`
+	AnswerKey      string `json:"answer_key,omitempty"`
+}
+
+// AttemptReviewItem представляет разбор ответа на один вопрос попытки.
+// Правильность и доля балла заполняются, только если их можно показывать.
+type AttemptReviewItem struct {
+	Position  int                     `json:"position"`
+	Question  TestQuestionStudentView `json:"question"`
+	Answer    json.RawMessage         `json:"answer,omitempty"` // Ответ в исходных индексах вариантов
+	IsCorrect *bool                   `json:"is_correct,omitempty"`
+	Credit    *float64                `json:"credit,omitempty"` // Доля балла от 0 до 1
+}
+
+// TestStudentView представляет тест в том виде, в котором его видит студент
+type TestStudentView struct {
+	ID                 uint      `json:"id"`
+	LessonID           uint      `json:"lesson_id"`
+	Title              string    `json:"title"`
+	Description        string    `json:"description"`
+	Type               string    `json:"type"`
+	ScoringMode        string    `json:"scoring_mode"`
+	AllowRetake        bool      `json:"allow_retake"`
+	MaxAttempts        int       `json:"max_attempts"`
+	RetakeCooldown     int       `json:"retake_cooldown"`
+	ScorePolicy        string    `json:"score_policy"`
+	ShowCorrectAnswers bool      `json:"show_correct_answers"`
+	TimeLimit          int       `json:"time_limit"`
+	Deadline                     // Окно сдачи и штраф за опоздание
+	QuestionCount      int       `json:"question_count"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	Lesson    Lesson                    `json:"lesson,omitempty"`
+	Questions []TestQuestionStudentView `json:"questions,omitempty"`
+}
+
+// LessonStudentView представляет урок с тестами в студенческом представлении
+type LessonStudentView struct {
+	Lesson
+	Tests []TestStudentView `json:"tests,omitempty"` // Перекрывает Lesson.Tests
+}
+
+// NewTestStudentView строит студенческое представление теста.
+// revealAnswers разрешает показать правильные ответы.
+func NewTestStudentView(test Test, revealAnswers bool) TestStudentView {
+	view := TestStudentView{
+		ID:                 test.ID,
+		LessonID:           test.LessonID,
+		Title:              test.Title,
+		Description:        test.Description,
+		Type:               test.Type,
+		ScoringMode:        test.ScoringMode,
+		AllowRetake:        test.AllowRetake,
+		MaxAttempts:        test.MaxAttempts,
+		RetakeCooldown:     test.RetakeCooldown,
+		ScorePolicy:        test.ScorePolicy,
+		ShowCorrectAnswers: test.ShowCorrectAnswers,
+		TimeLimit:          test.TimeLimit,
+		Deadline:           test.Deadline,
+		CreatedAt:          test.CreatedAt,
+		UpdatedAt:          test.UpdatedAt,
+		Lesson:             test.Lesson,
+	}
+
+	view.QuestionCount = len(test.Questions)
+	for _, rule := range test.PoolRules {
+		view.QuestionCount += rule.Count
+	}
+
+	// Вопросы теста с индивидуальной выборкой или ограничением времени
+	// студент получает только при начале попытки
+	if !test.RequiresSession() {
+		view.Questions = NewQuestionStudentViews(test, test.Questions, revealAnswers)
+	}
+
+	return view
+}
+
+// NewQuestionStudentViews строит студенческое представление вопросов теста.
+// revealAnswers разрешает показать правильные ответы, если тест это допускает.
+func NewQuestionStudentViews(test Test, questions []TestQuestion, revealAnswers bool) []TestQuestionStudentView {
+	reveal := revealAnswers && test.ShowCorrectAnswers
+	views := make([]TestQuestionStudentView, 0, len(questions))
+	for _, q := range questions {
+		qView := TestQuestionStudentView{
+			ID:           q.ID,
+			TestID:       test.ID,
+			Type:         q.EffectiveType(test.Type),
+			Question:     q.Question,
+			Options:      q.Options,
+			MatchOptions: q.MatchOptions,
+			Order:        q.Order,
+		}
+		if reveal {
+			correct := q.CorrectAnswer
+			qView.CorrectAnswer = &correct
+			qView.CorrectAnswers = q.CorrectAnswers
+			qView.AnswerKey = q.AnswerKey
+		}
+		views = append(views, qView)
+	}
+	return views
+}
+
+// NewLessonStudentView строит студенческое представление урока.
+// revealed содержит ID тестов, по которым студенту можно показать ответы.
+func NewLessonStudentView(lesson Lesson, revealed map[uint]bool) LessonStudentView {
+	view := LessonStudentView{Lesson: lesson}
+	view.Lesson.Tests = nil
+	for _, test := range lesson.Tests {
+		view.Tests = append(view.Tests, NewTestStudentView(test, revealed[test.ID]))
+	}
+	return view
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// RecoveryCode одноразовый код восстановления для входа без TOTP; хранится только SHA-256 кода
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorChallenge одноразовый токен второго шага входа, выдается после проверки пароля
+type TwoFactorChallenge struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	Attempts  int        `json:"-" gorm:"not null;default:0"` // Проверенные коды; после предела токен недействителен
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
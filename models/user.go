@@ -6,13 +6,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// Статусы учетной записи
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended" // Вход и запросы запрещены, данные сохраняются
+)
+
 // User представляет пользователя системы
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	Name      string         `json:"name" gorm:"not null"`
 	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
 	Password  string         `json:"-" gorm:"not null"` // Хеш пароля, не возвращаем в JSON
-	Role      string         `json:"role" gorm:"default:'student';check:role IN ('student', 'admin')"`
+	Role      string         `json:"role" gorm:"default:'student';check:role IN ('student', 'teacher', 'admin')"`
+	Status    string         `json:"status" gorm:"not null;default:'active';check:status IN ('active', 'suspended')"`
+	MustChangePassword bool  `json:"must_change_password" gorm:"default:false"` // Временный пароль, требуется смена при входе
+	TokenVersion int         `json:"-" gorm:"default:0"` // Увеличение отзывает все выданные access-токены
+	TwoFactorEnabled  bool   `json:"two_factor_enabled" gorm:"default:false"`
+	TwoFactorSecret   string `json:"-"` // Секрет TOTP (base32); до подтверждения - ожидающий подтверждения
+	TwoFactorLastStep int64  `json:"-"` // Последний принятый шаг TOTP, повторное использование кода запрещено
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -27,6 +39,16 @@ type User struct {
 
 // IsAdmin проверяет, является ли пользователь администратором
 func (u *User) IsAdmin() bool {
-	return u.Role == "admin"
+	return u.Role == RoleAdmin
+}
+
+// IsSuspended проверяет, заблокирована ли учетная запись
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
+
+// HasPermission проверяет, есть ли у пользователя право
+func (u *User) HasPermission(permission string) bool {
+	return RoleHasPermission(u.Role, permission)
 }
 
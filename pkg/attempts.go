@@ -0,0 +1,178 @@
+package pkg
+
+import (
+	"encoding/json"
+	"geografi-cheb/backend/models"
+	"log"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// ParseAttemptAnswers разбирает ответы попытки {question_id: ответ}
+func ParseAttemptAnswers(data string) map[string]json.RawMessage {
+	answers := map[string]json.RawMessage{}
+	if data != "" {
+		json.Unmarshal([]byte(data), &answers)
+	}
+	return answers
+}
+
+// ResolveAttemptQuestions возвращает вопросы попытки в порядке показа и перестановки вариантов.
+// Для попыток без сохраненного набора используются вопросы из записей ответов,
+// а если их нет - вопросы теста. test должен быть загружен с Questions.
+func ResolveAttemptQuestions(db *gorm.DB, attempt *models.TestAttempt, test *models.Test) ([]models.TestQuestion, map[string][]int) {
+	optionOrder := map[string][]int{}
+	if attempt.OptionOrder != "" {
+		json.Unmarshal([]byte(attempt.OptionOrder), &optionOrder)
+	}
+
+	var ids []uint
+	if attempt.QuestionIDs != "" {
+		json.Unmarshal([]byte(attempt.QuestionIDs), &ids)
+	}
+	if len(ids) == 0 && attempt.ID != 0 {
+		db.Model(&models.TestAttemptAnswer{}).Where("attempt_id = ?", attempt.ID).
+			Order("position ASC").Pluck("question_id", &ids)
+	}
+	if len(ids) == 0 {
+		questions := append([]models.TestQuestion(nil), test.Questions...)
+		sort.SliceStable(questions, func(i, j int) bool { return questions[i].Order < questions[j].Order })
+		return questions, optionOrder
+	}
+
+	// Вопросы могли быть удалены после начала попытки, но проверяться должны как были показаны
+	var loaded []models.TestQuestion
+	db.Unscoped().Where("id IN ?", ids).Find(&loaded)
+	byID := make(map[uint]models.TestQuestion, len(loaded))
+	for _, q := range loaded {
+		byID[q.ID] = q
+	}
+
+	questions := make([]models.TestQuestion, 0, len(ids))
+	for _, id := range ids {
+		if q, ok := byID[id]; ok {
+			questions = append(questions, q)
+		}
+	}
+	return questions, optionOrder
+}
+
+// SaveAttemptResults заменяет записи ответов попытки результатами проверки
+func SaveAttemptResults(tx *gorm.DB, attemptID uint, results []QuestionResult) error {
+	if err := tx.Unscoped().Where("attempt_id = ?", attemptID).Delete(&models.TestAttemptAnswer{}).Error; err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	records := make([]models.TestAttemptAnswer, 0, len(results))
+	for _, result := range results {
+		records = append(records, models.TestAttemptAnswer{
+			AttemptID:  attemptID,
+			QuestionID: result.QuestionID,
+			Position:   result.Position,
+			Answer:     string(result.Answer),
+			IsCorrect:  result.IsCorrect(),
+			Credit:     result.Credit,
+		})
+	}
+	return tx.Create(&records).Error
+}
+
+// RegradeAttempt заново проверяет завершенную попытку по текущим ключам ответов,
+// перезаписывает записи ответов и балл. Штраф за опоздание сохраняется.
+// Возвращает прежний и новый итоговый балл. test должен быть загружен с Questions.
+func RegradeAttempt(tx *gorm.DB, attempt *models.TestAttempt, test *models.Test) (float64, float64, error) {
+	oldScore := attempt.Score
+	questions, optionOrder := ResolveAttemptQuestions(tx, attempt, test)
+	results, rawScore := GradeAttempt(test, questions, ParseAttemptAnswers(attempt.Answers), optionOrder)
+	score := models.ApplyLatePenalty(rawScore, attempt.LatePenalty)
+
+	if err := SaveAttemptResults(tx, attempt.ID, results); err != nil {
+		return oldScore, oldScore, err
+	}
+	if score != oldScore || rawScore != attempt.RawScore {
+		if err := tx.Model(attempt).Updates(map[string]interface{}{"score": score, "raw_score": rawScore}).Error; err != nil {
+			return oldScore, oldScore, err
+		}
+	}
+	attempt.Score = score
+	attempt.RawScore = rawScore
+	return oldScore, score, nil
+}
+
+// BackfillAttemptAnswers переносит ответы из TestAttempt.Answers в записи TestAttemptAnswer
+// для завершенных попыток, у которых таких записей еще нет. Балл попытки не меняется.
+func BackfillAttemptAnswers(db *gorm.DB) error {
+	tests := make(map[uint]*models.Test)
+	migrated := 0
+
+	var attempts []models.TestAttempt
+	err := db.Where("status <> ? AND answers <> '' AND NOT EXISTS (SELECT 1 FROM test_attempt_answers WHERE test_attempt_answers.attempt_id = test_attempts.id)", models.AttemptInProgress).
+		FindInBatches(&attempts, 100, func(_ *gorm.DB, _ int) error {
+			for i := range attempts {
+				attempt := &attempts[i]
+
+				test, ok := tests[attempt.TestID]
+				if !ok {
+					test = &models.Test{}
+					if err := db.Unscoped().Preload("Questions").First(test, attempt.TestID).Error; err != nil {
+						continue
+					}
+					tests[attempt.TestID] = test
+				}
+
+				answers := ParseAttemptAnswers(attempt.Answers)
+				questions, optionOrder := ResolveAttemptQuestions(db, attempt, test)
+				if attempt.QuestionIDs == "" {
+					questions = legacyAttemptQuestions(db, test, questions, answers)
+				}
+
+				results, _ := GradeAttempt(test, questions, answers, optionOrder)
+				if err := SaveAttemptResults(db, attempt.ID, results); err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		log.Printf("Перенесены ответы %d попыток в test_attempt_answers", migrated)
+	}
+	return nil
+}
+
+// legacyAttemptQuestions подбирает вопросы для старой попытки без сохраненного набора.
+// Раньше UpdateTest пересоздавал вопросы, поэтому ответы могут ссылаться на удаленные вопросы теста:
+// в этом случае берутся именно они.
+func legacyAttemptQuestions(db *gorm.DB, test *models.Test, current []models.TestQuestion, answers map[string]json.RawMessage) []models.TestQuestion {
+	known := make(map[string]bool, len(current))
+	for _, q := range current {
+		known[strconv.Itoa(int(q.ID))] = true
+	}
+
+	var referenced []uint
+	allKnown := true
+	for questionID := range answers {
+		if !known[questionID] {
+			allKnown = false
+		}
+		if id, err := strconv.ParseUint(questionID, 10, 32); err == nil {
+			referenced = append(referenced, uint(id))
+		}
+	}
+	if allKnown || len(referenced) == 0 {
+		return current
+	}
+
+	var questions []models.TestQuestion
+	db.Unscoped().Where("id IN ? AND test_id = ?", referenced, test.ID).Order("\"order\" ASC").Find(&questions)
+	return questions
+}
@@ -0,0 +1,10 @@
+package pkg
+
+import "strings"
+
+// NormalizeEmail приводит email к виду, в котором он хранится и сравнивается:
+// без пробелов по краям и в нижнем регистре. Поиск по email - через LOWER(email) = ?,
+// чтобы находились и записи, сохраненные до нормализации.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
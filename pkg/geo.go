@@ -0,0 +1,249 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+// earthRadiusKm средний радиус Земли в километрах
+const earthRadiusKm = 6371.0
+
+// GeoPoint точка на карте (долгота, широта в градусах)
+type GeoPoint struct {
+	Lon float64
+	Lat float64
+}
+
+// Valid проверяет, что координаты находятся в допустимых пределах
+func (p GeoPoint) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// geoJSONGeometry геометрия GeoJSON (поддерживаются Point, Polygon, MultiPolygon)
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// geoJSONObject объект GeoJSON: Feature или сама геометрия
+type geoJSONObject struct {
+	Type        string           `json:"type"`
+	Geometry    *geoJSONGeometry `json:"geometry"`
+	Coordinates json.RawMessage  `json:"coordinates"`
+	Properties  json.RawMessage  `json:"properties"`
+}
+
+// GeoShape разобранная цель: точка или набор полигонов.
+// Каждый полигон - список колец, первое кольцо внешнее, остальные - дыры.
+type GeoShape struct {
+	Point    *GeoPoint
+	Polygons [][][]GeoPoint
+}
+
+// HaversineKm возвращает расстояние по дуге большого круга между точками в километрах
+func HaversineKm(a, b GeoPoint) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// lonDelta возвращает разницу долгот to - from, приведенную к [-180, 180),
+// чтобы фигуры, пересекающие меридиан ±180° (Чукотка), не разрывались
+func lonDelta(from, to float64) float64 {
+	d := math.Mod(to-from+180, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d - 180
+}
+
+// pointInRing проверяет попадание точки в кольцо методом трассировки луча.
+// Кольцо разворачивается по долготе от первой вершины, поэтому может пересекать меридиан ±180°.
+func pointInRing(p GeoPoint, ring []GeoPoint) bool {
+	if len(ring) == 0 {
+		return false
+	}
+	xs := make([]float64, len(ring))
+	xs[0] = ring[0].Lon
+	for i := 1; i < len(ring); i++ {
+		xs[i] = xs[i-1] + lonDelta(ring[i-1].Lon, ring[i].Lon)
+	}
+	px := ring[0].Lon + lonDelta(ring[0].Lon, p.Lon)
+
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			px < (xs[j]-xs[i])*(p.Lat-a.Lat)/(b.Lat-a.Lat)+xs[i] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PointInPolygon проверяет попадание точки в полигон с учетом дыр
+func PointInPolygon(p GeoPoint, polygon [][]GeoPoint) bool {
+	if len(polygon) == 0 || !pointInRing(p, polygon[0]) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if pointInRing(p, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// distanceToSegmentKm приближенно считает расстояние от точки до отрезка.
+// Отрезок проецируется на плоскость, касательную в точке p (достаточно для отрезков до сотен км).
+func distanceToSegmentKm(p, a, b GeoPoint) float64 {
+	kx := math.Cos(p.Lat * math.Pi / 180)
+	aLon := lonDelta(p.Lon, a.Lon)
+	bLon := aLon + lonDelta(a.Lon, b.Lon)
+	ax, ay := aLon*kx, a.Lat-p.Lat
+	bx, by := bLon*kx, b.Lat-p.Lat
+
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if lenSq := dx*dx + dy*dy; lenSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lenSq))
+	}
+
+	nearest := GeoPoint{
+		Lon: p.Lon + (ax+t*dx)/math.Max(kx, 1e-9),
+		Lat: p.Lat + ay + t*dy,
+	}
+	return HaversineKm(p, nearest)
+}
+
+// DistanceToShapeKm возвращает расстояние от точки до цели (0, если точка внутри полигона)
+func DistanceToShapeKm(p GeoPoint, shape GeoShape) float64 {
+	if shape.Point != nil {
+		return HaversineKm(p, *shape.Point)
+	}
+
+	best := math.Inf(1)
+	for _, polygon := range shape.Polygons {
+		if PointInPolygon(p, polygon) {
+			return 0
+		}
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				best = math.Min(best, distanceToSegmentKm(p, ring[i], ring[i+1]))
+			}
+		}
+	}
+	return best
+}
+
+// parsePosition разбирает позицию GeoJSON [долгота, широта]
+func parsePosition(pos []float64) (GeoPoint, error) {
+	if len(pos) < 2 {
+		return GeoPoint{}, errors.New("позиция должна содержать долготу и широту")
+	}
+	p := GeoPoint{Lon: pos[0], Lat: pos[1]}
+	if !p.Valid() {
+		return GeoPoint{}, errors.New("координаты вне допустимого диапазона")
+	}
+	return p, nil
+}
+
+// parseRings разбирает кольца полигона GeoJSON
+func parseRings(rings [][][]float64) ([][]GeoPoint, error) {
+	if len(rings) == 0 {
+		return nil, errors.New("полигон не содержит колец")
+	}
+	polygon := make([][]GeoPoint, 0, len(rings))
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			return nil, errors.New("кольцо полигона должно содержать минимум 4 позиции")
+		}
+		points := make([]GeoPoint, 0, len(ring))
+		for _, pos := range ring {
+			p, err := parsePosition(pos)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, p)
+		}
+		polygon = append(polygon, points)
+	}
+	return polygon, nil
+}
+
+// ParseGeoShape разбирает GeoJSON (Feature или геометрию) с типом Point, Polygon или MultiPolygon
+func ParseGeoShape(data []byte) (GeoShape, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return GeoShape{}, errors.New("некорректный GeoJSON")
+	}
+
+	geometry := geoJSONGeometry{Type: obj.Type, Coordinates: obj.Coordinates}
+	if obj.Type == "Feature" {
+		if obj.Geometry == nil {
+			return GeoShape{}, errors.New("у Feature нет геометрии")
+		}
+		geometry = *obj.Geometry
+	}
+
+	switch geometry.Type {
+	case "Point":
+		var pos []float64
+		if err := json.Unmarshal(geometry.Coordinates, &pos); err != nil {
+			return GeoShape{}, errors.New("некорректные координаты точки")
+		}
+		p, err := parsePosition(pos)
+		if err != nil {
+			return GeoShape{}, err
+		}
+		return GeoShape{Point: &p}, nil
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &rings); err != nil {
+			return GeoShape{}, errors.New("некорректные координаты полигона")
+		}
+		polygon, err := parseRings(rings)
+		if err != nil {
+			return GeoShape{}, err
+		}
+		return GeoShape{Polygons: [][][]GeoPoint{polygon}}, nil
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil || len(polygons) == 0 {
+			return GeoShape{}, errors.New("некорректные координаты мультиполигона")
+		}
+		shape := GeoShape{}
+		for _, rings := range polygons {
+			polygon, err := parseRings(rings)
+			if err != nil {
+				return GeoShape{}, err
+			}
+			shape.Polygons = append(shape.Polygons, polygon)
+		}
+		return shape, nil
+	default:
+		return GeoShape{}, errors.New("поддерживаются только Point, Polygon и MultiPolygon")
+	}
+}
+
+// ParseGeoPoint разбирает ответ студента: GeoJSON Point/Feature или массив [долгота, широта]
+func ParseGeoPoint(data []byte) (GeoPoint, error) {
+	var pos []float64
+	if err := json.Unmarshal(data, &pos); err == nil {
+		return parsePosition(pos)
+	}
+
+	shape, err := ParseGeoShape(data)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	if shape.Point == nil {
+		return GeoPoint{}, errors.New("ответ должен быть точкой")
+	}
+	return *shape.Point, nil
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+)
+
+// chukotka полигон, пересекающий меридиан ±180°
+var chukotka = [][]GeoPoint{{{170, 60}, {-170, 60}, {-170, 70}, {170, 70}, {170, 60}}}
+
+func TestHaversineKm(t *testing.T) {
+	moscow := GeoPoint{Lon: 37.6173, Lat: 55.7558}
+	petersburg := GeoPoint{Lon: 30.3141, Lat: 59.9386}
+	if d := HaversineKm(moscow, petersburg); math.Abs(d-634) > 5 {
+		t.Errorf("Москва - Санкт-Петербург: %.1f км, ожидалось около 634", d)
+	}
+	if d := HaversineKm(GeoPoint{Lon: 179.5, Lat: 0}, GeoPoint{Lon: -179.5, Lat: 0}); math.Abs(d-111.2) > 1 {
+		t.Errorf("через меридиан 180°: %.1f км, ожидалось около 111", d)
+	}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	square := [][]GeoPoint{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}}, // Дыра
+	}
+	tests := []struct {
+		name    string
+		polygon [][]GeoPoint
+		point   GeoPoint
+		want    bool
+	}{
+		{"внутри", square, GeoPoint{2, 2}, true},
+		{"в дыре", square, GeoPoint{5, 5}, false},
+		{"снаружи", square, GeoPoint{12, 5}, false},
+		{"через 180° восточнее", chukotka, GeoPoint{175, 65}, true},
+		{"через 180° западнее", chukotka, GeoPoint{-175, 65}, true},
+		{"на меридиане 180°", chukotka, GeoPoint{180, 65}, true},
+		{"противоположная сторона Земли", chukotka, GeoPoint{0, 65}, false},
+		{"южнее", chukotka, GeoPoint{175, 55}, false},
+	}
+	for _, tt := range tests {
+		if got := PointInPolygon(tt.point, tt.polygon); got != tt.want {
+			t.Errorf("%s: %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceToShapeKm(t *testing.T) {
+	shape := GeoShape{Polygons: [][][]GeoPoint{chukotka}}
+	if d := DistanceToShapeKm(GeoPoint{175, 65}, shape); d != 0 {
+		t.Errorf("точка внутри полигона: %.1f км", d)
+	}
+	// 5° долготы на широте 65°
+	want := 5 * math.Cos(65*math.Pi/180) * earthRadiusKm * math.Pi / 180
+	for _, p := range []GeoPoint{{165, 65}, {-165, 65}} {
+		if d := DistanceToShapeKm(p, shape); math.Abs(d-want) > want*0.02 {
+			t.Errorf("%v: %.1f км до границы, ожидалось около %.1f", p, d, want)
+		}
+	}
+}
+
+func TestParseGeoPoint(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    GeoPoint
+		wantErr bool
+	}{
+		{`[37.6, 55.7]`, GeoPoint{37.6, 55.7}, false},
+		{`{"type":"Point","coordinates":[37.6,55.7]}`, GeoPoint{37.6, 55.7}, false},
+		{`{"type":"Feature","geometry":{"type":"Point","coordinates":[-170,65]}}`, GeoPoint{-170, 65}, false},
+		{`[200, 0]`, GeoPoint{}, true},
+		{`[37.6]`, GeoPoint{}, true},
+		{`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`, GeoPoint{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGeoPoint([]byte(tt.data))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: %v, %v", tt.data, got, err)
+		}
+	}
+}
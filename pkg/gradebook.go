@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"fmt"
+	"geografi-cheb/backend/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// Источники оценки в ячейке журнала
+const (
+	CellSourceGrade   = "grade"   // Оценка преподавателя
+	CellSourceAttempt = "attempt" // Автоматический балл за попытки по правилу теста
+)
+
+// GradebookColumn задание в журнале (тест или практика)
+type GradebookColumn struct {
+	Type     string     `json:"type"` // test или practice
+	ID       uint       `json:"id"`
+	Title    string     `json:"title"`
+	LessonID uint       `json:"lesson_id"`
+	DueAt    *time.Time `json:"due_at"`
+}
+
+// GradebookCell оценка студента за задание
+type GradebookCell struct {
+	Value   float64 `json:"value"`           // Исходная оценка или балл
+	Percent float64 `json:"percent"`         // Оценка в процентах (0-100)
+	Mark    int     `json:"mark"`            // Оценка по пятибалльной шкале
+	Source  string  `json:"source"`          // grade или attempt
+	Scale   string  `json:"scale,omitempty"` // Шкала оценки преподавателя: five или percent
+}
+
+// GradebookStudent студент в журнале
+type GradebookStudent struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// GradebookRow строка журнала: оценки студента по заданиям и средние
+type GradebookRow struct {
+	Student         GradebookStudent `json:"student"`
+	Cells           []*GradebookCell `json:"cells"`            // По порядку колонок, nil - нет оценки
+	TestAverage     *float64         `json:"test_average"`     // Средний процент по тестам
+	PracticeAverage *float64         `json:"practice_average"` // Средний процент по практикам
+	Average         *float64         `json:"average"`          // Взвешенный средний процент
+	Mark            int              `json:"mark"`             // Итоговая оценка по пятибалльной шкале (0 - нет оценок)
+}
+
+// Gradebook журнал оценок: студенты × задания
+type Gradebook struct {
+	Weights models.GradeWeights `json:"weights"`
+	Columns []GradebookColumn   `json:"columns"`
+	Rows    []GradebookRow      `json:"rows"`
+	Average *float64            `json:"average"` // Средний процент по всем студентам с оценками
+}
+
+// GradebookData исходные данные для журнала
+type GradebookData struct {
+	Students       []models.User
+	Tests          []models.Test
+	Practices      []models.Practice
+	TestGrades     []models.TestGrade
+	PracticeGrades []models.PracticeGrade
+	Attempts       []models.TestAttempt // Отсортированы по времени создания
+}
+
+// NormalizeGrade переводит оценку преподавателя в проценты по ее шкале:
+// пятибалльная оценка умножается на 20, проценты берутся как есть
+func NormalizeGrade(grade float64, scale string) float64 {
+	if scale == models.GradeScaleFive {
+		grade *= 20
+	}
+	return math.Max(0, math.Min(100, grade))
+}
+
+// FivePointMark переводит процент в оценку по пятибалльной шкале
+func FivePointMark(percent float64) int {
+	switch {
+	case percent >= 85:
+		return 5
+	case percent >= 70:
+		return 4
+	case percent >= 50:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// BuildGradebook собирает журнал. Оценка преподавателя за тест имеет приоритет
+// над автоматическим баллом; задания без оценки в средних не учитываются.
+func BuildGradebook(data GradebookData, weights models.GradeWeights) Gradebook {
+	book := Gradebook{Weights: weights, Columns: gradebookColumns(data.Tests, data.Practices), Rows: []GradebookRow{}}
+
+	type key struct {
+		userID, itemID uint
+	}
+	type scaledGrade struct {
+		value float64
+		scale string
+	}
+	testGrades := make(map[key]scaledGrade)
+	for _, grade := range data.TestGrades {
+		testGrades[key{grade.UserID, grade.TestID}] = scaledGrade{grade.Grade, grade.Scale}
+	}
+	practiceGrades := make(map[key]scaledGrade)
+	for _, grade := range data.PracticeGrades {
+		practiceGrades[key{grade.UserID, grade.PracticeID}] = scaledGrade{grade.Grade, grade.Scale}
+	}
+	attempts := make(map[key][]models.TestAttempt)
+	for _, attempt := range data.Attempts {
+		k := key{attempt.UserID, attempt.TestID}
+		attempts[k] = append(attempts[k], attempt)
+	}
+	tests := make(map[uint]*models.Test, len(data.Tests))
+	for i := range data.Tests {
+		tests[data.Tests[i].ID] = &data.Tests[i]
+	}
+
+	students := append([]models.User(nil), data.Students...)
+	sort.SliceStable(students, func(i, j int) bool {
+		if students[i].Name != students[j].Name {
+			return students[i].Name < students[j].Name
+		}
+		return students[i].ID < students[j].ID
+	})
+
+	var total float64
+	var graded int
+	for _, student := range students {
+		row := GradebookRow{
+			Student: GradebookStudent{ID: student.ID, Name: student.Name, Email: student.Email},
+			Cells:   make([]*GradebookCell, len(book.Columns)),
+		}
+		var testSum, practiceSum float64
+		var testCount, practiceCount int
+
+		for i, column := range book.Columns {
+			k := key{student.ID, column.ID}
+			var cell *GradebookCell
+			switch column.Type {
+			case models.AssignmentTest:
+				if grade, ok := testGrades[k]; ok {
+					cell = newGradebookCell(grade.value, NormalizeGrade(grade.value, grade.scale), CellSourceGrade)
+					cell.Scale = grade.scale
+				} else if score, ok := tests[column.ID].EffectiveScore(attempts[k]); ok {
+					cell = newGradebookCell(score, score, CellSourceAttempt)
+				}
+				if cell != nil {
+					testSum += cell.Percent
+					testCount++
+				}
+			case models.AssignmentPractice:
+				if grade, ok := practiceGrades[k]; ok {
+					cell = newGradebookCell(grade.value, NormalizeGrade(grade.value, grade.scale), CellSourceGrade)
+					cell.Scale = grade.scale
+					practiceSum += cell.Percent
+					practiceCount++
+				}
+			}
+			row.Cells[i] = cell
+		}
+
+		row.TestAverage = average(testSum, testCount)
+		row.PracticeAverage = average(practiceSum, practiceCount)
+		row.Average = weightedAverage(row.TestAverage, row.PracticeAverage, weights)
+		if row.Average != nil {
+			row.Mark = FivePointMark(*row.Average)
+			total += *row.Average
+			graded++
+		}
+		book.Rows = append(book.Rows, row)
+	}
+
+	book.Average = average(total, graded)
+	return book
+}
+
+// gradebookColumns возвращает колонки журнала в стабильном порядке:
+// по сроку сдачи (без срока - в конце), затем тесты перед практиками и по ID
+func gradebookColumns(tests []models.Test, practices []models.Practice) []GradebookColumn {
+	columns := make([]GradebookColumn, 0, len(tests)+len(practices))
+	for _, test := range tests {
+		columns = append(columns, GradebookColumn{Type: models.AssignmentTest, ID: test.ID, Title: test.Title, LessonID: test.LessonID, DueAt: test.DueAt})
+	}
+	for _, practice := range practices {
+		columns = append(columns, GradebookColumn{Type: models.AssignmentPractice, ID: practice.ID, Title: practice.Title, LessonID: practice.LessonID, DueAt: practice.DueAt})
+	}
+
+	sort.SliceStable(columns, func(i, j int) bool {
+		a, b := columns[i], columns[j]
+		if (a.DueAt == nil) != (b.DueAt == nil) {
+			return a.DueAt != nil
+		}
+		if a.DueAt != nil && !a.DueAt.Equal(*b.DueAt) {
+			return a.DueAt.Before(*b.DueAt)
+		}
+		if a.Type != b.Type {
+			return a.Type == models.AssignmentTest
+		}
+		return a.ID < b.ID
+	})
+	return columns
+}
+
+func newGradebookCell(value, percent float64, source string) *GradebookCell {
+	return &GradebookCell{Value: value, Percent: percent, Mark: FivePointMark(percent), Source: source}
+}
+
+func average(sum float64, count int) *float64 {
+	if count == 0 {
+		return nil
+	}
+	avg := sum / float64(count)
+	return &avg
+}
+
+// weightedAverage считает взвешенное среднее по категориям, в которых есть оценки
+func weightedAverage(testAverage, practiceAverage *float64, weights models.GradeWeights) *float64 {
+	var sum, weight float64
+	if testAverage != nil && weights.TestWeight > 0 {
+		sum += *testAverage * weights.TestWeight
+		weight += weights.TestWeight
+	}
+	if practiceAverage != nil && weights.PracticeWeight > 0 {
+		sum += *practiceAverage * weights.PracticeWeight
+		weight += weights.PracticeWeight
+	}
+	if weight == 0 {
+		return nil
+	}
+	avg := sum / weight
+	return &avg
+}
+
+// GradebookTable представляет журнал таблицей для выгрузки: заголовок и строка на студента.
+// Колонки: student_id, name, email, задания в порядке журнала, средние и итоговая оценка.
+// При marks в ячейках заданий пятибалльные оценки, иначе проценты; нет оценки - nil.
+func GradebookTable(book Gradebook, marks bool) [][]interface{} {
+	header := []interface{}{"student_id", "name", "email"}
+	for _, column := range book.Columns {
+		header = append(header, fmt.Sprintf("%s_%d: %s", column.Type, column.ID, column.Title))
+	}
+	header = append(header, "test_average", "practice_average", "average", "mark")
+
+	table := [][]interface{}{header}
+	for _, row := range book.Rows {
+		line := []interface{}{row.Student.ID, row.Student.Name, row.Student.Email}
+		for _, cell := range row.Cells {
+			switch {
+			case cell == nil:
+				line = append(line, nil)
+			case marks:
+				line = append(line, cell.Mark)
+			default:
+				line = append(line, roundGrade(cell.Percent))
+			}
+		}
+		line = append(line, roundGradePtr(row.TestAverage), roundGradePtr(row.PracticeAverage), roundGradePtr(row.Average))
+		if row.Mark > 0 {
+			line = append(line, row.Mark)
+		} else {
+			line = append(line, nil)
+		}
+		table = append(table, line)
+	}
+	return table
+}
+
+// roundGrade округляет процент до сотых
+func roundGrade(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func roundGradePtr(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return roundGrade(*v)
+}
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGradebookTable(t *testing.T) {
+	average := 78.333333
+	book := Gradebook{
+		Columns: []GradebookColumn{{Type: "test", ID: 3, Title: "Реки"}, {Type: "practice", ID: 4, Title: "Карта"}},
+		Rows: []GradebookRow{{
+			Student:     GradebookStudent{ID: 1, Name: "Иванов", Email: "ivanov@example.com"},
+			Cells:       []*GradebookCell{{Percent: 78.333333, Mark: 4}, nil},
+			TestAverage: &average,
+			Average:     &average,
+			Mark:        4,
+		}, {
+			Student: GradebookStudent{ID: 2, Name: "Петров", Email: "petrov@example.com"},
+			Cells:   []*GradebookCell{nil, nil},
+		}},
+	}
+
+	wantHeader := []interface{}{"student_id", "name", "email", "test_3: Реки", "practice_4: Карта", "test_average", "practice_average", "average", "mark"}
+	percents := GradebookTable(book, false)
+	if len(percents) != 3 || !reflect.DeepEqual(percents[0], wantHeader) {
+		t.Fatalf("заголовок: %v", percents[0])
+	}
+	if want := []interface{}{uint(1), "Иванов", "ivanov@example.com", 78.33, nil, 78.33, nil, 78.33, 4}; !reflect.DeepEqual(percents[1], want) {
+		t.Errorf("строка в процентах: %v", percents[1])
+	}
+	if want := []interface{}{uint(2), "Петров", "petrov@example.com", nil, nil, nil, nil, nil, nil}; !reflect.DeepEqual(percents[2], want) {
+		t.Errorf("строка без оценок: %v", percents[2])
+	}
+
+	if marks := GradebookTable(book, true); marks[1][3] != 4 {
+		t.Errorf("ячейка в пятибалльной шкале: %v", marks[1][3])
+	}
+}
@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"geografi-cheb/backend/models"
+	"strconv"
+)
+
+// Режимы подсчета баллов за вопросы, допускающие частично верный ответ
+const (
+	ScoringAllOrNothing = "all_or_nothing" // Балл только за полностью верный ответ
+	ScoringPartial      = "partial"        // Частичный балл (для multiple - со штрафом за неверные варианты)
+)
+
+// IsValidScoringMode проверяет режим подсчета баллов
+func IsValidScoringMode(mode string) bool {
+	return mode == ScoringAllOrNothing || mode == ScoringPartial
+}
+
+// QuestionGrader проверяет вопросы одного типа
+type QuestionGrader interface {
+	// Validate проверяет варианты и ключ ответа при сохранении вопроса
+	Validate(q *models.TestQuestion) error
+	// Grade возвращает долю балла (от 0 до 1) за ответ студента
+	Grade(q *models.TestQuestion, answer json.RawMessage, scoringMode string) float64
+}
+
+var graders = map[string]QuestionGrader{}
+
+// RegisterQuestionGrader регистрирует проверяющего для типа вопроса
+func RegisterQuestionGrader(questionType string, grader QuestionGrader) {
+	graders[questionType] = grader
+}
+
+// GetQuestionGrader возвращает проверяющего для типа вопроса
+func GetQuestionGrader(questionType string) (QuestionGrader, bool) {
+	grader, ok := graders[questionType]
+	return grader, ok
+}
+
+func init() {
+	RegisterQuestionGrader(models.QuestionSingle, choiceGrader{multiple: false})
+	RegisterQuestionGrader(models.QuestionMultiple, choiceGrader{multiple: true})
+	RegisterQuestionGrader(models.QuestionNumeric, numericGrader{})
+	RegisterQuestionGrader(models.QuestionText, textGrader{})
+	RegisterQuestionGrader(models.QuestionMatching, matchingGrader{})
+	RegisterQuestionGrader(models.QuestionOrdering, orderingGrader{})
+	RegisterQuestionGrader(models.QuestionMap, mapGrader{})
+}
+
+// GradeQuestion возвращает долю балла (от 0 до 1) за ответ на вопрос теста
+func GradeQuestion(test *models.Test, q *models.TestQuestion, answer json.RawMessage) float64 {
+	grader, ok := GetQuestionGrader(q.EffectiveType(test.Type))
+	if !ok {
+		return 0
+	}
+	return grader.Grade(q, answer, test.ScoringMode)
+}
+
+// QuestionResult результат проверки ответа на один вопрос попытки
+type QuestionResult struct {
+	QuestionID uint
+	Position   int             // Позиция вопроса в попытке (с 1)
+	Answer     json.RawMessage // Ответ в исходных индексах вариантов (nil - нет ответа)
+	Credit     float64         // Доля балла от 0 до 1
+}
+
+// IsCorrect проверяет, что ответ полностью верный
+func (r QuestionResult) IsCorrect() bool {
+	return r.Credit >= 1
+}
+
+// GradeAttempt проверяет ответы по вопросам конкретной попытки.
+// optionOrder содержит перестановки вариантов {question_id: perm}, в которых студент видел вопросы.
+// Возвращает результаты по каждому вопросу и итоговый балл (0-100); каждый вопрос стоит одинаково.
+func GradeAttempt(test *models.Test, questions []models.TestQuestion, answers map[string]json.RawMessage, optionOrder map[string][]int) ([]QuestionResult, float64) {
+	results := make([]QuestionResult, 0, len(questions))
+	earned := 0.0
+
+	for i := range questions {
+		question := &questions[i]
+		result := QuestionResult{
+			QuestionID: question.ID,
+			Position:   i + 1,
+		}
+
+		questionID := strconv.Itoa(int(question.ID))
+		if raw, ok := answers[questionID]; ok {
+			result.Answer = UnshuffleAnswer(question, test.Type, optionOrder[questionID], raw)
+			result.Credit = GradeQuestion(test, question, result.Answer)
+			earned += result.Credit
+		}
+
+		results = append(results, result)
+	}
+
+	if len(questions) == 0 {
+		return results, 0
+	}
+	return results, (earned / float64(len(questions))) * 100
+}
+
+// CorrectIndices возвращает набор правильных вариантов вопроса.
+// Для старых вопросов без correct_answers используется correct_answer.
+func CorrectIndices(q *models.TestQuestion) []int {
+	if q.CorrectAnswers != "" {
+		var indices []int
+		if err := json.Unmarshal([]byte(q.CorrectAnswers), &indices); err == nil && len(indices) > 0 {
+			return indices
+		}
+	}
+	return []int{q.CorrectAnswer}
+}
+
+// ValidateAnswerSet проверяет набор правильных вариантов: не пустой, без повторов, в пределах optionsCount
+func ValidateAnswerSet(indices []int, optionsCount int) error {
+	if len(indices) == 0 {
+		return errors.New("не указаны правильные ответы")
+	}
+	seen := make(map[int]bool, len(indices))
+	for _, idx := range indices {
+		if idx < 0 || idx >= optionsCount {
+			return errors.New("некорректный индекс правильного ответа: " + strconv.Itoa(idx))
+		}
+		if seen[idx] {
+			return errors.New("повторяющийся индекс правильного ответа: " + strconv.Itoa(idx))
+		}
+		seen[idx] = true
+	}
+	return nil
+}
+
+// parseIndices разбирает ответ студента: одно число или массив чисел
+func parseIndices(raw json.RawMessage) ([]int, bool) {
+	var single int
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []int{single}, true
+	}
+	var many []int
+	if err := json.Unmarshal(raw, &many); err == nil {
+		return many, true
+	}
+	return nil, false
+}
+
+// parseStringList разбирает JSON массив строк из поля модели
+func parseStringList(data string) []string {
+	var list []string
+	json.Unmarshal([]byte(data), &list)
+	return list
+}
+
+// choiceGrader проверяет вопросы с выбором одного или нескольких вариантов
+type choiceGrader struct {
+	multiple bool
+}
+
+func (g choiceGrader) Validate(q *models.TestQuestion) error {
+	options := parseStringList(q.Options)
+	if len(options) < 2 {
+		return errors.New("нужно минимум два варианта ответа")
+	}
+	correct := CorrectIndices(q)
+	if !g.multiple && len(correct) != 1 {
+		return errors.New("у вопроса с одним ответом должен быть ровно один правильный вариант")
+	}
+	return ValidateAnswerSet(correct, len(options))
+}
+
+// Grade для single засчитывает только один выбранный правильный вариант.
+// Для multiple в режиме partial балл равен доле угаданных правильных вариантов
+// минус доля выбранных неправильных, но не меньше нуля.
+func (g choiceGrader) Grade(q *models.TestQuestion, raw json.RawMessage, scoringMode string) float64 {
+	picked, ok := parseIndices(raw)
+	if !ok || len(picked) == 0 {
+		return 0
+	}
+
+	correct := make(map[int]bool)
+	for _, idx := range CorrectIndices(q) {
+		correct[idx] = true
+	}
+
+	if !g.multiple {
+		if len(picked) == 1 && correct[picked[0]] {
+			return 1
+		}
+		return 0
+	}
+
+	// Убираем повторы в ответе студента
+	chosen := make(map[int]bool, len(picked))
+	for _, idx := range picked {
+		chosen[idx] = true
+	}
+
+	hits, wrong := 0, 0
+	for idx := range chosen {
+		if correct[idx] {
+			hits++
+		} else {
+			wrong++
+		}
+	}
+
+	if scoringMode != ScoringPartial {
+		if hits == len(correct) && wrong == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	incorrectCount := len(parseStringList(q.Options)) - len(correct)
+
+	credit := float64(hits) / float64(len(correct))
+	if wrong > 0 {
+		if incorrectCount > 0 {
+			credit -= float64(wrong) / float64(incorrectCount)
+		} else {
+			credit = 0
+		}
+	}
+	if credit < 0 {
+		credit = 0
+	}
+	return credit
+}
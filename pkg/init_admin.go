@@ -1,48 +1,121 @@
 package pkg
 
 import (
+	"errors"
+	"fmt"
 	"geografi-cheb/backend/models"
 	"log"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// InitAdmin создает администратора по умолчанию, если его еще нет
-func InitAdmin(db *gorm.DB) {
-	var admin models.User
-	result := db.Where("email = ?", "admin@geography.edu").First(&admin)
+// AdminBootstrap параметры создания первого администратора
+type AdminBootstrap struct {
+	Name       string
+	Email      string
+	Password   string // Вместе с Email создает администратора при запуске
+	SetupToken string // Токен для POST /auth/setup; пустой - генерируется
+}
+
+// setupTokenSize - размер генерируемого токена первоначальной настройки в байтах
+const setupTokenSize = 32
 
-	// Если администратор уже существует, выходим
-	if result.Error == nil {
+// InitAdmin создает первого администратора, если в системе нет ни одного.
+// При заданных Email и Password администратор создается сразу и должен сменить пароль при первом входе.
+// Иначе включается первоначальная настройка через POST /auth/setup по одноразовому токену,
+// который выводится в лог (пароль в лог не выводится никогда).
+func InitAdmin(db *gorm.DB, params AdminBootstrap) error {
+	var admins int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins > 0 {
 		log.Println("Администратор уже существует")
-		return
+		return db.Where("key = ?", models.SettingAdminSetupTokenHash).Delete(&models.Setting{}).Error
 	}
 
-	// Создаем нового администратора
-	hashedPassword, err := HashPassword("admin123")
-	if err != nil {
-		log.Printf("Ошибка хеширования пароля администратора: %v", err)
-		return
+	if params.Email != "" && params.Password != "" {
+		return createBootstrapAdmin(db, params)
 	}
 
-	admin = models.User{
-		Name:     "Администратор",
-		Email:    "admin@geography.edu",
-		Password: hashedPassword,
-		Role:     "admin",
+	token := params.SetupToken
+	if token == "" {
+		var err error
+		if token, err = RandomToken(setupTokenSize); err != nil {
+			return err
+		}
 	}
-
-	if err := db.Create(&admin).Error; err != nil {
-		log.Printf("Ошибка создания администратора: %v", err)
-		return
+	setting := models.Setting{Key: models.SettingAdminSetupTokenHash, Value: HashToken(token)}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		return err
 	}
 
 	log.Println("========================================")
-	log.Println("✅ АДМИНИСТРАТОР СОЗДАН ПО УМОЛЧАНИЮ")
-	log.Println("========================================")
-	log.Println("Email:    admin@geography.edu")
-	log.Println("Пароль:   admin123")
-	log.Println("Роль:     admin")
+	log.Println("АДМИНИСТРАТОР НЕ НАСТРОЕН")
+	log.Println("Создайте его через POST /api/v1/auth/setup")
+	if params.SetupToken == "" {
+		log.Printf("Одноразовый токен настройки: %s", token)
+		log.Println("Токен меняется при каждом запуске, пока администратор не создан")
+	} else {
+		log.Println("Используйте токен из ADMIN_SETUP_TOKEN")
+	}
 	log.Println("========================================")
+	return nil
 }
 
+// createBootstrapAdmin создает администратора из переменных окружения с обязательной сменой пароля.
+// Существующая учетная запись с тем же email (в том числе удаленная) не захватывается: запуск прерывается.
+func createBootstrapAdmin(db *gorm.DB, params AdminBootstrap) error {
+	params.Email = NormalizeEmail(params.Email)
+	var existing models.User
+	err := db.Unscoped().Where("LOWER(email) = ?", params.Email).First(&existing).Error
+	if err == nil {
+		return fmt.Errorf("ADMIN_EMAIL %s уже принадлежит пользователю #%d (роль %s): укажите другой адрес", params.Email, existing.ID, existing.Role)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	hashedPassword, err := HashPassword(params.Password)
+	if err != nil {
+		return err
+	}
+	admin := models.User{
+		Name:               params.Name,
+		Email:              params.Email,
+		Password:           hashedPassword,
+		Role:               models.RoleAdmin,
+		MustChangePassword: true,
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Администратор %s создан из ADMIN_EMAIL, пароль нужно сменить при первом входе", params.Email)
+	return db.Where("key = ?", models.SettingAdminSetupTokenHash).Delete(&models.Setting{}).Error
+}
+
+// ExpireAdminPassword требует смены пароля у администраторов, чей пароль совпадает с password,
+// и возвращает их количество
+func ExpireAdminPassword(db *gorm.DB, password string) (int, error) {
+	var admins []models.User
+	if err := db.Where("role = ?", models.RoleAdmin).Find(&admins).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, admin := range admins {
+		if !CheckPassword(password, admin.Password) {
+			continue
+		}
+		count++
+		if err := db.Model(&admin).Update("must_change_password", true).Error; err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
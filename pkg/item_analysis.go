@@ -0,0 +1,271 @@
+package pkg
+
+import (
+	"geografi-cheb/backend/models"
+	"math"
+	"sort"
+)
+
+// OptionFrequency частота выбора варианта ответа
+type OptionFrequency struct {
+	Index     int     `json:"index"`
+	Option    string  `json:"option"`
+	IsCorrect bool    `json:"is_correct"`
+	Count     int     `json:"count"`
+	Rate      float64 `json:"rate"` // Доля ответивших, выбравших вариант (0-1)
+}
+
+// ItemStats показатели вопроса по завершенным попыткам
+type ItemStats struct {
+	QuestionID     uint              `json:"question_id"`
+	Question       string            `json:"question"`
+	Type           string            `json:"type"`
+	Responses      int               `json:"responses"`       // В скольких попытках встречался вопрос
+	Omitted        int               `json:"omitted"`         // Сколько раз вопрос оставлен без ответа
+	PercentCorrect float64           `json:"percent_correct"` // Доля полностью верных ответов (0-100)
+	Difficulty     float64           `json:"difficulty"`      // Средняя доля балла, p-value (0-1)
+	Discrimination *float64          `json:"discrimination"`  // Точечно-бисериальная корреляция с баллом за остальные вопросы
+	Options        []OptionFrequency `json:"options,omitempty"`
+}
+
+// ItemAnalysis отчет по качеству вопросов теста
+type ItemAnalysis struct {
+	TestID        uint        `json:"test_id"`
+	Attempts      int         `json:"attempts"`
+	MeanScore     float64     `json:"mean_score"`
+	StdDev        float64     `json:"std_dev"`
+	CommonItems   int         `json:"common_items"`   // Вопросы, входившие во все попытки (по ним считается надежность)
+	CronbachAlpha *float64    `json:"cronbach_alpha"` // nil, если данных недостаточно
+	KR20          *float64    `json:"kr20"`
+	Items         []ItemStats `json:"items"`
+}
+
+// AnalyzeItems считает трудность и дискриминативность вопросов, частоты выбора вариантов
+// и надежность теста. attempts должны быть загружены с AnswerRecords, questions - все вопросы,
+// встречавшиеся в попытках.
+func AnalyzeItems(test *models.Test, questions []models.TestQuestion, attempts []models.TestAttempt) ItemAnalysis {
+	report := ItemAnalysis{TestID: test.ID, Attempts: len(attempts), Items: []ItemStats{}}
+	if len(attempts) == 0 {
+		return report
+	}
+
+	byID := make(map[uint]*models.TestQuestion, len(questions))
+	for i := range questions {
+		byID[questions[i].ID] = &questions[i]
+	}
+
+	// Набранные баллы и число вопросов каждой попытки (вопрос стоит 1 балл)
+	earned := make([]float64, len(attempts))
+	maximum := make([]float64, len(attempts))
+	scores := make([]float64, len(attempts))
+	appearances := map[uint]int{}
+	var order []uint
+	for i, attempt := range attempts {
+		for _, record := range attempt.AnswerRecords {
+			earned[i] += record.Credit
+			maximum[i]++
+			if appearances[record.QuestionID] == 0 {
+				order = append(order, record.QuestionID)
+			}
+			appearances[record.QuestionID]++
+		}
+		scores[i] = attempt.Score
+	}
+	report.MeanScore, report.StdDev = meanStd(scores)
+
+	for _, questionID := range order {
+		q, ok := byID[questionID]
+		if !ok {
+			continue
+		}
+		report.Items = append(report.Items, analyzeItem(test, q, attempts, earned, maximum))
+	}
+
+	report.CronbachAlpha, report.KR20, report.CommonItems = reliability(attempts, order, appearances)
+	return report
+}
+
+// analyzeItem считает показатели одного вопроса
+func analyzeItem(test *models.Test, q *models.TestQuestion, attempts []models.TestAttempt, earned, maximum []float64) ItemStats {
+	questionType := q.EffectiveType(test.Type)
+	stats := ItemStats{QuestionID: q.ID, Question: q.Question, Type: questionType}
+
+	isChoice := questionType == models.QuestionSingle || questionType == models.QuestionMultiple
+	var options []string
+	var counts []int
+	if isChoice {
+		options = parseStringList(q.Options)
+		counts = make([]int, len(options))
+	}
+
+	var credits, rest []float64
+	correct := 0
+	for i, attempt := range attempts {
+		for _, record := range attempt.AnswerRecords {
+			if record.QuestionID != q.ID {
+				continue
+			}
+			stats.Responses++
+			credits = append(credits, record.Credit)
+			if record.IsCorrect {
+				correct++
+			}
+
+			// Балл за остальные вопросы попытки в процентах, чтобы вопрос не коррелировал сам с собой
+			restScore := 0.0
+			if restMax := maximum[i] - 1; restMax > 0 {
+				restScore = (earned[i] - record.Credit) / restMax
+			}
+			rest = append(rest, restScore)
+
+			if record.Answer == "" {
+				stats.Omitted++
+				continue
+			}
+			if isChoice {
+				if picked, ok := parseIndices([]byte(record.Answer)); ok {
+					for _, idx := range uniqueIndices(picked) {
+						if idx >= 0 && idx < len(counts) {
+							counts[idx]++
+						}
+					}
+				}
+			}
+		}
+	}
+	if stats.Responses == 0 {
+		return stats
+	}
+
+	stats.PercentCorrect = float64(correct) / float64(stats.Responses) * 100
+	stats.Difficulty, _ = meanStd(credits)
+	stats.Discrimination = correlation(credits, rest)
+
+	if isChoice {
+		correctSet := map[int]bool{}
+		for _, idx := range CorrectIndices(q) {
+			correctSet[idx] = true
+		}
+		for idx, option := range options {
+			stats.Options = append(stats.Options, OptionFrequency{
+				Index:     idx,
+				Option:    option,
+				IsCorrect: correctSet[idx],
+				Count:     counts[idx],
+				Rate:      float64(counts[idx]) / float64(stats.Responses),
+			})
+		}
+	}
+	return stats
+}
+
+// reliability считает альфу Кронбаха (по баллам) и KR-20 (по верно/неверно)
+// на вопросах, которые встречались во всех попытках
+func reliability(attempts []models.TestAttempt, order []uint, appearances map[uint]int) (*float64, *float64, int) {
+	var common []uint
+	for _, questionID := range order {
+		if appearances[questionID] == len(attempts) {
+			common = append(common, questionID)
+		}
+	}
+	k := len(common)
+	if k < 2 || len(attempts) < 2 {
+		return nil, nil, k
+	}
+
+	index := make(map[uint]int, k)
+	for i, questionID := range common {
+		index[questionID] = i
+	}
+	points := make([][]float64, k)
+	binary := make([][]float64, k)
+	for i := range common {
+		points[i] = make([]float64, len(attempts))
+		binary[i] = make([]float64, len(attempts))
+	}
+	for a, attempt := range attempts {
+		for _, record := range attempt.AnswerRecords {
+			i, ok := index[record.QuestionID]
+			if !ok {
+				continue
+			}
+			points[i][a] = record.Credit
+			if record.IsCorrect {
+				binary[i][a] = 1
+			}
+		}
+	}
+
+	return cronbachAlpha(points), cronbachAlpha(binary), k
+}
+
+// cronbachAlpha считает альфу Кронбаха по матрице вопрос x попытка.
+// Для дихотомических (0/1) баллов совпадает с KR-20.
+func cronbachAlpha(items [][]float64) *float64 {
+	k := len(items)
+	n := len(items[0])
+	totals := make([]float64, n)
+	sumItemVar := 0.0
+	for _, item := range items {
+		_, sd := meanStd(item)
+		sumItemVar += sd * sd
+		for a, v := range item {
+			totals[a] += v
+		}
+	}
+	_, totalSD := meanStd(totals)
+	if totalSD == 0 {
+		return nil
+	}
+	alpha := float64(k) / float64(k-1) * (1 - sumItemVar/(totalSD*totalSD))
+	return &alpha
+}
+
+// correlation считает коэффициент корреляции Пирсона; nil, если одна из величин постоянна
+func correlation(x, y []float64) *float64 {
+	if len(x) < 2 || len(x) != len(y) {
+		return nil
+	}
+	meanX, sdX := meanStd(x)
+	meanY, sdY := meanStd(y)
+	if sdX == 0 || sdY == 0 {
+		return nil
+	}
+	cov := 0.0
+	for i := range x {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+	}
+	r := cov / float64(len(x)) / (sdX * sdY)
+	return &r
+}
+
+// meanStd возвращает среднее и стандартное отклонение генеральной совокупности
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// uniqueIndices возвращает индексы без повторов в порядке возрастания
+func uniqueIndices(indices []int) []int {
+	seen := make(map[int]bool, len(indices))
+	var unique []int
+	for _, idx := range indices {
+		if !seen[idx] {
+			seen[idx] = true
+			unique = append(unique, idx)
+		}
+	}
+	sort.Ints(unique)
+	return unique
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"errors"
+	"geografi-cheb/backend/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,27 +10,48 @@ import (
 
 var jwtSecret = []byte("your-secret-key-change-in-production") // Должно загружаться из конфига
 
+var accessTokenTTL = 15 * time.Minute
+
 // SetJWTSecret устанавливает секретный ключ для JWT
 func SetJWTSecret(secret string) {
 	jwtSecret = []byte(secret)
 }
 
+// SetAccessTokenTTL устанавливает время жизни access-токена
+func SetAccessTokenTTL(ttl time.Duration) {
+	accessTokenTTL = ttl
+}
+
+// AccessTokenTTL возвращает время жизни access-токена
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
 // Claims представляет структуру JWT токена
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID                 uint   `json:"user_id"`
+	Email                  string `json:"email"`
+	Role                   string `json:"role"`
+	MustChangePassword     bool   `json:"must_change_password,omitempty"`      // Доступна только смена пароля
+	SessionID              uint   `json:"sid"`                                 // Сессия, выдавшая токен
+	TokenVersion           int    `json:"ver"`                                 // Должна совпадать с версией пользователя
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required,omitempty"` // Доступна только настройка 2FA
 	jwt.RegisteredClaims
 }
 
-// GenerateToken генерирует JWT токен для пользователя
-func GenerateToken(userID uint, email, role string) (string, error) {
+// GenerateToken генерирует короткоживущий access-токен пользователя для сессии.
+// twoFactorSetupRequired ограничивает токен настройкой двухфакторной аутентификации.
+func GenerateToken(user *models.User, sessionID uint, twoFactorSetupRequired bool) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:                 user.ID,
+		Email:                  user.Email,
+		Role:                   user.Role,
+		MustChangePassword:     user.MustChangePassword,
+		SessionID:              sessionID,
+		TokenVersion:           user.TokenVersion,
+		TwoFactorSetupRequired: twoFactorSetupRequired,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
@@ -58,4 +80,3 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return nil, errors.New("невалидный токен")
 }
-
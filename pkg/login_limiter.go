@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// LimiterEntry состояние счетчика неудачных попыток
+type LimiterEntry struct {
+	Failures     int       // Неудачные попытки в текущем окне
+	WindowEndsAt time.Time // Конец окна подсчета
+	BlockedUntil time.Time // Попытки запрещены до этого времени
+}
+
+// LimiterStore хранилище счетчиков неудачных попыток входа
+type LimiterStore interface {
+	// Get возвращает состояние ключа; для неизвестного ключа - пустое состояние
+	Get(key string, now time.Time) (LimiterEntry, error)
+	// Update атомарно изменяет состояние ключа функцией update: параллельные вызовы
+	// по одному ключу выполняются по очереди. Истекшее окно начинается заново до вызова update.
+	Update(key string, now time.Time, window time.Duration, update func(entry *LimiterEntry)) (LimiterEntry, error)
+	// Reset удаляет счетчик ключа
+	Reset(key string) error
+	// Cleanup удаляет счетчики с истекшими окном и блокировкой
+	Cleanup(now time.Time) error
+}
+
+// LoginAttempt результат резервирования попытки входа
+type LoginAttempt struct {
+	Wait            time.Duration // Попытка отклонена, повторить можно через Wait
+	AccountFailures int
+	AccountLocked   bool // Аккаунт будет заблокирован, если эта попытка неудачна
+	IPLocked        bool // IP будет заблокирован, если эта попытка неудачна
+}
+
+// LoginLimiter ограничивает попытки входа по аккаунту и по IP.
+// После каждой неудачи по аккаунту следующая попытка разрешается с растущей задержкой
+// (DelayBase, 2×DelayBase, ... до MaxDelay), после MaxAccountFailures неудач аккаунт
+// блокируется на LockoutDuration. IP блокируется после MaxIPFailures неудач.
+//
+// Попытка учитывается как неудачная еще до проверки пароля (Attempt) и возвращается
+// после успешной проверки (Release), поэтому параллельные запросы не проходят мимо
+// задержки и блокировки.
+type LoginLimiter struct {
+	Store              LimiterStore
+	MaxAccountFailures int
+	MaxIPFailures      int
+	Window             time.Duration
+	LockoutDuration    time.Duration
+	DelayBase          time.Duration
+	MaxDelay           time.Duration
+}
+
+func accountLimiterKey(email string) string {
+	return "account:" + NormalizeEmail(email)
+}
+
+func ipLimiterKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt резервирует попытку входа: если вход для IP и email не заблокирован,
+// попытка сразу учитывается как неудачная и включает задержку для следующей.
+// При отказе счетчики не меняются, а Wait показывает время до следующей попытки.
+func (l *LoginLimiter) Attempt(ip, email string) (LoginAttempt, error) {
+	now := time.Now()
+	var result LoginAttempt
+
+	_, err := l.Store.Update(ipLimiterKey(ip), now, l.Window, func(entry *LimiterEntry) {
+		if entry.BlockedUntil.After(now) {
+			result.Wait = entry.BlockedUntil.Sub(now)
+			return
+		}
+		entry.Failures++
+		if entry.Failures >= l.MaxIPFailures {
+			result.IPLocked = true
+			entry.BlockedUntil = now.Add(l.LockoutDuration)
+		}
+	})
+	if err != nil || result.Wait > 0 {
+		return result, err
+	}
+
+	_, err = l.Store.Update(accountLimiterKey(email), now, l.Window, func(entry *LimiterEntry) {
+		if entry.BlockedUntil.After(now) {
+			result.Wait = entry.BlockedUntil.Sub(now)
+			return
+		}
+		entry.Failures++
+		result.AccountFailures = entry.Failures
+		if entry.Failures >= l.MaxAccountFailures {
+			result.AccountLocked = true
+			entry.BlockedUntil = now.Add(l.LockoutDuration)
+		} else {
+			entry.BlockedUntil = now.Add(l.delay(entry.Failures))
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+	if result.Wait > 0 {
+		// Отклоненная попытка не проверяла пароль и не расходует лимит IP
+		result.IPLocked = false
+		return result, l.release(ipLimiterKey(ip), l.MaxIPFailures)
+	}
+	return result, nil
+}
+
+// Release возвращает попытку, зарезервированную Attempt, после успешной проверки
+// пароля или кода: счетчики уменьшаются, задержка аккаунта снимается
+func (l *LoginLimiter) Release(ip, email string) error {
+	if err := l.release(accountLimiterKey(email), l.MaxAccountFailures); err != nil {
+		return err
+	}
+	return l.release(ipLimiterKey(ip), l.MaxIPFailures)
+}
+
+// release уменьшает счетчик ключа; блокировка снимается, только если без этой
+// попытки предел неудач не достигнут (ее могли установить параллельные неудачи)
+func (l *LoginLimiter) release(key string, max int) error {
+	_, err := l.Store.Update(key, time.Now(), l.Window, func(entry *LimiterEntry) {
+		if entry.Failures > 0 {
+			entry.Failures--
+		}
+		if entry.Failures < max {
+			entry.BlockedUntil = time.Time{}
+		}
+	})
+	return err
+}
+
+// Succeed сбрасывает счетчик аккаунта после успешного входа.
+// Счетчик IP не сбрасывается, чтобы вход в свой аккаунт не обнулял перебор чужих.
+func (l *LoginLimiter) Succeed(email string) error {
+	return l.Store.Reset(accountLimiterKey(email))
+}
+
+// Unlock снимает блокировку входа в аккаунт
+func (l *LoginLimiter) Unlock(email string) error {
+	return l.Store.Reset(accountLimiterKey(email))
+}
+
+// delay возвращает задержку перед следующей попыткой после failures неудач
+func (l *LoginLimiter) delay(failures int) time.Duration {
+	d := l.DelayBase
+	for i := 1; i < failures && d < l.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > l.MaxDelay {
+		d = l.MaxDelay
+	}
+	return d
+}
+
+// MemoryLimiterStore хранит счетчики в памяти процесса; подходит для одного экземпляра сервера
+type MemoryLimiterStore struct {
+	mu      sync.Mutex
+	entries map[string]LimiterEntry
+}
+
+// NewMemoryLimiterStore создает хранилище счетчиков в памяти
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{entries: make(map[string]LimiterEntry)}
+}
+
+// Get возвращает состояние ключа
+func (s *MemoryLimiterStore) Get(key string, now time.Time) (LimiterEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entries[key]
+	if !now.Before(entry.WindowEndsAt) {
+		entry.Failures = 0
+	}
+	return entry, nil
+}
+
+// Update атомарно изменяет состояние ключа
+func (s *MemoryLimiterStore) Update(key string, now time.Time, window time.Duration, update func(entry *LimiterEntry)) (LimiterEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entries[key]
+	if !now.Before(entry.WindowEndsAt) {
+		entry.Failures = 0
+		entry.WindowEndsAt = now.Add(window)
+	}
+	update(&entry)
+	s.entries[key] = entry
+	return entry, nil
+}
+
+// Reset удаляет счетчик
+func (s *MemoryLimiterStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Cleanup удаляет истекшие счетчики
+func (s *MemoryLimiterStore) Cleanup(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.entries {
+		if !now.Before(entry.WindowEndsAt) && !now.Before(entry.BlockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
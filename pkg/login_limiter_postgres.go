@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"geografi-cheb/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresLimiterStore хранит счетчики в таблице login_throttles;
+// счетчики общие для всех экземпляров сервера
+type PostgresLimiterStore struct {
+	DB *gorm.DB
+}
+
+// Get возвращает состояние ключа
+func (s *PostgresLimiterStore) Get(key string, now time.Time) (LimiterEntry, error) {
+	var rows []models.LoginThrottle
+	if err := s.DB.Where("key = ?", key).Limit(1).Find(&rows).Error; err != nil {
+		return LimiterEntry{}, err
+	}
+	if len(rows) == 0 {
+		return LimiterEntry{}, nil
+	}
+	entry := throttleEntry(rows[0])
+	if !now.Before(entry.WindowEndsAt) {
+		entry.Failures = 0
+	}
+	return entry, nil
+}
+
+// Update изменяет состояние в транзакции под блокировкой строки ключа,
+// чтобы параллельные попытки разных экземпляров выполнялись по очереди
+func (s *PostgresLimiterStore) Update(key string, now time.Time, window time.Duration, update func(entry *LimiterEntry)) (LimiterEntry, error) {
+	var entry LimiterEntry
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Строка создается заранее: заблокировать можно только существующую
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key, WindowEndsAt: now}).Error; err != nil {
+			return err
+		}
+		var row models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		entry = throttleEntry(row)
+		if !now.Before(entry.WindowEndsAt) {
+			entry.Failures = 0
+			entry.WindowEndsAt = now.Add(window)
+		}
+		update(&entry)
+
+		row.Failures = entry.Failures
+		row.WindowEndsAt = entry.WindowEndsAt
+		row.BlockedUntil = nil
+		if !entry.BlockedUntil.IsZero() {
+			row.BlockedUntil = &entry.BlockedUntil
+		}
+		return tx.Save(&row).Error
+	})
+	return entry, err
+}
+
+// Reset удаляет счетчик
+func (s *PostgresLimiterStore) Reset(key string) error {
+	return s.DB.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// Cleanup удаляет истекшие счетчики
+func (s *PostgresLimiterStore) Cleanup(now time.Time) error {
+	return s.DB.Where("window_ends_at <= ? AND (blocked_until IS NULL OR blocked_until <= ?)", now, now).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+func throttleEntry(row models.LoginThrottle) LimiterEntry {
+	entry := LimiterEntry{Failures: row.Failures, WindowEndsAt: row.WindowEndsAt}
+	if row.BlockedUntil != nil {
+		entry.BlockedUntil = *row.BlockedUntil
+	}
+	return entry
+}
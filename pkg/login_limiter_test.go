@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"fmt"
+	"geografi-cheb/backend/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// limiterStores возвращает хранилища для проверки: в памяти и в БД (SQLite вместо PostgreSQL)
+func limiterStores(t *testing.T) map[string]func() LimiterStore {
+	return map[string]func() LimiterStore{
+		"memory": func() LimiterStore { return NewMemoryLimiterStore() },
+		"postgres": func() LimiterStore {
+			dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+			db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// SQLite не допускает параллельных транзакций записи
+			sqlDB, _ := db.DB()
+			sqlDB.SetMaxOpenConns(1)
+			if err := db.AutoMigrate(&models.LoginThrottle{}); err != nil {
+				t.Fatal(err)
+			}
+			return &PostgresLimiterStore{DB: db}
+		},
+	}
+}
+
+func newTestLimiter(store LimiterStore) *LoginLimiter {
+	return &LoginLimiter{
+		Store:              store,
+		MaxAccountFailures: 3,
+		MaxIPFailures:      5,
+		Window:             time.Hour,
+		LockoutDuration:    time.Hour,
+		DelayBase:          time.Minute,
+		MaxDelay:           10 * time.Minute,
+	}
+}
+
+// clearHold снимает задержку аккаунта, как будто она уже истекла
+func clearHold(t *testing.T, l *LoginLimiter, email string) {
+	t.Helper()
+	if _, err := l.Store.Update(accountLimiterKey(email), time.Now(), l.Window, func(entry *LimiterEntry) {
+		entry.BlockedUntil = time.Time{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginLimiterDelayAndLockout(t *testing.T) {
+	for name, newStore := range limiterStores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := newTestLimiter(newStore())
+
+			first, err := l.Attempt("10.0.0.1", "User@Example.com")
+			if err != nil || first.Wait != 0 || first.AccountFailures != 1 {
+				t.Fatalf("первая попытка: %+v, %v", first, err)
+			}
+			// Пока действует задержка, попытка отклоняется и не учитывается
+			second, err := l.Attempt("10.0.0.1", "user@example.com")
+			if err != nil || second.Wait <= 0 || second.Wait > l.DelayBase {
+				t.Fatalf("попытка во время задержки: %+v, %v", second, err)
+			}
+
+			clearHold(t, l, "user@example.com")
+			third, _ := l.Attempt("10.0.0.1", "user@example.com")
+			if third.Wait != 0 || third.AccountFailures != 2 || third.AccountLocked {
+				t.Fatalf("вторая учтенная попытка: %+v", third)
+			}
+			clearHold(t, l, "user@example.com")
+			fourth, _ := l.Attempt("10.0.0.1", "user@example.com")
+			if !fourth.AccountLocked {
+				t.Fatalf("аккаунт не заблокирован после %d неудач: %+v", l.MaxAccountFailures, fourth)
+			}
+			if locked, _ := l.Attempt("10.0.0.1", "user@example.com"); locked.Wait <= l.MaxDelay {
+				t.Fatalf("блокировка аккаунта не действует: %+v", locked)
+			}
+
+			if err := l.Unlock("user@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if unlocked, _ := l.Attempt("10.0.0.1", "user@example.com"); unlocked.Wait != 0 {
+				t.Fatalf("попытка после снятия блокировки: %+v", unlocked)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterRelease(t *testing.T) {
+	for name, newStore := range limiterStores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := newTestLimiter(newStore())
+
+			// Последняя разрешенная попытка с верным паролем не блокирует аккаунт
+			for i := 1; i < l.MaxAccountFailures; i++ {
+				if attempt, _ := l.Attempt("10.0.0.1", "user@example.com"); attempt.Wait != 0 {
+					t.Fatalf("попытка %d: %+v", i, attempt)
+				}
+				clearHold(t, l, "user@example.com")
+			}
+			last, _ := l.Attempt("10.0.0.1", "user@example.com")
+			if !last.AccountLocked {
+				t.Fatalf("последняя попытка: %+v", last)
+			}
+			if err := l.Release("10.0.0.1", "user@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			account, _ := l.Store.Get(accountLimiterKey("user@example.com"), time.Now())
+			if account.Failures != l.MaxAccountFailures-1 || !account.BlockedUntil.IsZero() {
+				t.Fatalf("счетчик аккаунта после успешной проверки: %+v", account)
+			}
+			address, _ := l.Store.Get(ipLimiterKey("10.0.0.1"), time.Now())
+			if address.Failures != l.MaxAccountFailures-1 {
+				t.Fatalf("счетчик IP после успешной проверки: %+v", address)
+			}
+			if next, _ := l.Attempt("10.0.0.1", "user@example.com"); next.Wait != 0 {
+				t.Fatalf("попытка после успешной проверки отклонена: %+v", next)
+			}
+		})
+	}
+}
+
+// TestLoginLimiterConcurrentAttempts проверяет, что параллельные запросы
+// не проходят мимо задержки аккаунта и блокировки IP
+func TestLoginLimiterConcurrentAttempts(t *testing.T) {
+	const callers = 20
+	for name, newStore := range limiterStores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := newTestLimiter(newStore())
+			run := func(email func(i int) string) int {
+				var (
+					wg      sync.WaitGroup
+					mu      sync.Mutex
+					allowed int
+				)
+				for i := 0; i < callers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						attempt, err := l.Attempt("10.0.0.1", email(i))
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if attempt.Wait == 0 {
+							mu.Lock()
+							allowed++
+							mu.Unlock()
+						}
+					}(i)
+				}
+				wg.Wait()
+				return allowed
+			}
+
+			if allowed := run(func(int) string { return "user@example.com" }); allowed != 1 {
+				t.Fatalf("для одного аккаунта прошло %d параллельных попыток, ожидалась 1", allowed)
+			}
+			allowed := run(func(i int) string { return fmt.Sprintf("user%d@example.com", i) })
+			if want := l.MaxIPFailures - 1; allowed != want {
+				t.Fatalf("с одного IP прошло %d параллельных попыток, ожидалось %d", allowed, want)
+			}
+		})
+	}
+}
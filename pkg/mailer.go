@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer отправляет письма пользователям
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer отправляет письма через SMTP-сервер (STARTTLS, если сервер поддерживает)
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send отправляет текстовое письмо
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, buildMessage(m.From, to, subject, body))
+}
+
+// LogMailer записывает письма в файл или, если путь не задан, в лог.
+// Предназначен для локальной разработки и тестирования.
+type LogMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+// Send записывает письмо
+func (m *LogMailer) Send(to, subject, body string) error {
+	message := buildMessage(m.From, to, subject, body)
+	if m.Path == "" {
+		log.Printf("Письмо:\n%s", message)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n\n", message)
+	return err
+}
+
+// buildMessage собирает письмо в формате RFC 5322 в кодировке UTF-8
+func buildMessage(from, to, subject, body string) []byte {
+	// Переводы строк в заголовках недопустимы
+	clean := strings.NewReplacer("\r", "", "\n", "")
+	headers := []string{
+		"From: " + clean.Replace(from),
+		"To: " + clean.Replace(to),
+		"Subject: =?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(clean.Replace(subject))) + "?=",
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(body, "\n", "\r\n"))
+}
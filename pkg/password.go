@@ -1,6 +1,11 @@
 package pkg
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"runtime"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // HashPassword хеширует пароль
 func HashPassword(password string) (string, error) {
@@ -14,3 +19,33 @@ func CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
+// HashPasswords хеширует несколько паролей параллельно, не больше чем в runtime.NumCPU() горутинах.
+// Возвращает хеши в порядке паролей или первую ошибку.
+func HashPasswords(passwords []string) ([]string, error) {
+	hashes := make([]string, len(passwords))
+	errs := make([]error, len(passwords))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU() && w < len(passwords); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hashes[i], errs[i] = HashPassword(passwords[i])
+			}
+		}()
+	}
+	for i := range passwords {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
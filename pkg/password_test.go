@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHashPasswords(t *testing.T) {
+	passwords := make([]string, 5)
+	for i := range passwords {
+		passwords[i] = "password" + strconv.Itoa(i)
+	}
+	hashes, err := HashPasswords(passwords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != len(passwords) {
+		t.Fatalf("хешей %d, ожидалось %d", len(hashes), len(passwords))
+	}
+	for i, hash := range hashes {
+		if !CheckPassword(passwords[i], hash) {
+			t.Errorf("хеш %d не соответствует своему паролю", i)
+		}
+	}
+	if hashes, err := HashPasswords(nil); err != nil || len(hashes) != 0 {
+		t.Errorf("пустой список: %v, %v", hashes, err)
+	}
+}
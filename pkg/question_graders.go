@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"geografi-cheb/backend/models"
+	"math"
+	"strings"
+)
+
+// NumericAnswerKey ключ числового вопроса: ответ засчитывается, если |ответ - value| <= tolerance
+type NumericAnswerKey struct {
+	Value     float64 `json:"value"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+// TextAnswerKey ключ текстового вопроса: список допустимых ответов (синонимов)
+type TextAnswerKey struct {
+	Answers       []string `json:"answers"`
+	CaseSensitive bool     `json:"case_sensitive"`
+}
+
+// MatchingAnswerKey ключ вопроса на сопоставление:
+// Pairs[i] - индекс элемента правого столбца для i-го элемента левого
+type MatchingAnswerKey struct {
+	Pairs []int `json:"pairs"`
+}
+
+// OrderingAnswerKey ключ вопроса на упорядочивание:
+// Order - индексы вариантов в правильном порядке
+type OrderingAnswerKey struct {
+	Order []int `json:"order"`
+}
+
+// numericGrader проверяет числовые ответы с допуском
+type numericGrader struct{}
+
+func (numericGrader) Validate(q *models.TestQuestion) error {
+	// value обязателен: без него ответ сравнивался бы с нулем
+	var key struct {
+		Value     *float64 `json:"value"`
+		Tolerance float64  `json:"tolerance"`
+	}
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil || key.Value == nil {
+		return errors.New("ключ ответа должен иметь вид {\"value\": число, \"tolerance\": число}")
+	}
+	if key.Tolerance < 0 {
+		return errors.New("допуск не может быть отрицательным")
+	}
+	return nil
+}
+
+func (numericGrader) Grade(q *models.TestQuestion, raw json.RawMessage, scoringMode string) float64 {
+	var key NumericAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil {
+		return 0
+	}
+
+	var answer float64
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		// Допускаем число, переданное строкой, в том числе с запятой
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return 0
+		}
+		if err := json.Unmarshal([]byte(strings.Replace(strings.TrimSpace(text), ",", ".", 1)), &answer); err != nil {
+			return 0
+		}
+	}
+
+	if math.Abs(answer-key.Value) <= key.Tolerance {
+		return 1
+	}
+	return 0
+}
+
+// textGrader проверяет короткие текстовые ответы по списку синонимов
+type textGrader struct{}
+
+func (textGrader) Validate(q *models.TestQuestion) error {
+	var key TextAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil || len(key.Answers) == 0 {
+		return errors.New("ключ ответа должен содержать непустой список answers")
+	}
+	for _, answer := range key.Answers {
+		if strings.TrimSpace(answer) == "" {
+			return errors.New("допустимый ответ не может быть пустым")
+		}
+	}
+	return nil
+}
+
+func (textGrader) Grade(q *models.TestQuestion, raw json.RawMessage, scoringMode string) float64 {
+	var key TextAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil {
+		return 0
+	}
+
+	var answer string
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return 0
+	}
+
+	normalized := normalizeTextAnswer(answer, key.CaseSensitive)
+	if normalized == "" {
+		return 0
+	}
+	for _, accepted := range key.Answers {
+		if normalizeTextAnswer(accepted, key.CaseSensitive) == normalized {
+			return 1
+		}
+	}
+	return 0
+}
+
+// normalizeTextAnswer убирает лишние пробелы, заменяет ё на е и при необходимости приводит к нижнему регистру
+func normalizeTextAnswer(s string, caseSensitive bool) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.NewReplacer("ё", "е", "Ё", "Е").Replace(s)
+	if !caseSensitive {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// matchingGrader проверяет сопоставление элементов двух столбцов
+type matchingGrader struct{}
+
+func (matchingGrader) Validate(q *models.TestQuestion) error {
+	left := parseStringList(q.Options)
+	right := parseStringList(q.MatchOptions)
+	if len(left) < 2 || len(right) < 2 {
+		return errors.New("для сопоставления нужно минимум по два элемента в каждом столбце")
+	}
+
+	var key MatchingAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil {
+		return errors.New("ключ ответа должен иметь вид {\"pairs\": [индексы правого столбца]}")
+	}
+	if len(key.Pairs) != len(left) {
+		return errors.New("в ключе должна быть пара для каждого элемента левого столбца")
+	}
+	for _, idx := range key.Pairs {
+		if idx < 0 || idx >= len(right) {
+			return errors.New("некорректный индекс правого столбца в ключе")
+		}
+	}
+	return nil
+}
+
+// Grade в режиме partial засчитывает долю верных пар
+func (matchingGrader) Grade(q *models.TestQuestion, raw json.RawMessage, scoringMode string) float64 {
+	var key MatchingAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil || len(key.Pairs) == 0 {
+		return 0
+	}
+
+	var answer []int
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return 0
+	}
+
+	return positionalCredit(key.Pairs, answer, scoringMode)
+}
+
+// orderingGrader проверяет упорядочивание вариантов
+type orderingGrader struct{}
+
+func (orderingGrader) Validate(q *models.TestQuestion) error {
+	options := parseStringList(q.Options)
+	if len(options) < 2 {
+		return errors.New("для упорядочивания нужно минимум два элемента")
+	}
+
+	var key OrderingAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil {
+		return errors.New("ключ ответа должен иметь вид {\"order\": [индексы вариантов]}")
+	}
+	if len(key.Order) != len(options) {
+		return errors.New("в ключе должны быть перечислены все варианты")
+	}
+	return ValidateAnswerSet(key.Order, len(options))
+}
+
+// Grade в режиме partial засчитывает долю элементов, стоящих на своем месте
+func (orderingGrader) Grade(q *models.TestQuestion, raw json.RawMessage, scoringMode string) float64 {
+	var key OrderingAnswerKey
+	if err := json.Unmarshal([]byte(q.AnswerKey), &key); err != nil || len(key.Order) == 0 {
+		return 0
+	}
+
+	var answer []int
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return 0
+	}
+
+	return positionalCredit(key.Order, answer, scoringMode)
+}
+
+// positionalCredit сравнивает ответ с ключом поэлементно
+func positionalCredit(expected, answer []int, scoringMode string) float64 {
+	matched := 0
+	for i, want := range expected {
+		if i < len(answer) && answer[i] == want {
+			matched++
+		}
+	}
+
+	if scoringMode == ScoringPartial {
+		return float64(matched) / float64(len(expected))
+	}
+	if matched == len(expected) && len(answer) == len(expected) {
+		return 1
+	}
+	return 0
+}
+
+// MapAnswerProperties радиусы засчитывания ответа на карте (свойства Feature в ключе)
+type MapAnswerProperties struct {
+	FullCreditRadiusKm    float64 `json:"full_credit_radius_km"`    // Полный балл, если ответ не дальше этого радиуса
+	PartialCreditRadiusKm float64 `json:"partial_credit_radius_km"` // Частичный балл линейно убывает до этого радиуса
+}
+
+// parseMapKey разбирает ключ вопроса на карте: GeoJSON Feature с Point, Polygon или MultiPolygon
+func parseMapKey(answerKey string) (GeoShape, MapAnswerProperties, error) {
+	var props MapAnswerProperties
+	shape, err := ParseGeoShape([]byte(answerKey))
+	if err != nil {
+		return GeoShape{}, props, err
+	}
+
+	var feature struct {
+		Properties *MapAnswerProperties `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(answerKey), &feature); err == nil && feature.Properties != nil {
+		props = *feature.Properties
+	}
+	return shape, props, nil
+}
+
+// mapGrader проверяет ответы-точки на карте по расстоянию до цели
+type mapGrader struct{}
+
+func (mapGrader) Validate(q *models.TestQuestion) error {
+	shape, props, err := parseMapKey(q.AnswerKey)
+	if err != nil {
+		return err
+	}
+	if props.FullCreditRadiusKm < 0 || props.PartialCreditRadiusKm < 0 {
+		return errors.New("радиусы не могут быть отрицательными")
+	}
+	if props.PartialCreditRadiusKm > 0 && props.PartialCreditRadiusKm < props.FullCreditRadiusKm {
+		return errors.New("радиус частичного балла должен быть не меньше радиуса полного балла")
+	}
+	if shape.Point != nil && props.FullCreditRadiusKm == 0 {
+		return errors.New("для точки нужно указать full_credit_radius_km")
+	}
+	return nil
+}
+
+// Grade дает полный балл внутри радиуса full_credit_radius_km (или внутри полигона).
+// Если у вопроса задан partial_credit_radius_km, дальше балл линейно убывает до нуля
+// на этом радиусе независимо от режима подсчета теста.
+func (mapGrader) Grade(q *models.TestQuestion, raw json.RawMessage, scoringMode string) float64 {
+	shape, props, err := parseMapKey(q.AnswerKey)
+	if err != nil {
+		return 0
+	}
+
+	point, err := ParseGeoPoint(raw)
+	if err != nil {
+		return 0
+	}
+
+	distance := DistanceToShapeKm(point, shape)
+	if distance <= props.FullCreditRadiusKm {
+		return 1
+	}
+	if distance < props.PartialCreditRadiusKm {
+		return 1 - (distance-props.FullCreditRadiusKm)/(props.PartialCreditRadiusKm-props.FullCreditRadiusKm)
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"encoding/json"
+	"geografi-cheb/backend/models"
+	"testing"
+)
+
+// gradeCase ответ студента и ожидаемая доля балла
+type gradeCase struct {
+	name   string
+	answer string
+	mode   string
+	want   float64
+}
+
+// checkGrades проверяет долю балла за ответы на вопрос
+func checkGrades(t *testing.T, grader QuestionGrader, q *models.TestQuestion, cases []gradeCase) {
+	t.Helper()
+	for _, tc := range cases {
+		mode := tc.mode
+		if mode == "" {
+			mode = ScoringAllOrNothing
+		}
+		if got := grader.Grade(q, json.RawMessage(tc.answer), mode); !floatEqual(got, tc.want) {
+			t.Errorf("%s: балл %.3f, ожидалось %.3f", tc.name, got, tc.want)
+		}
+	}
+}
+
+func floatEqual(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
+
+func TestMapGrader(t *testing.T) {
+	moscow := &models.TestQuestion{AnswerKey: `{"type":"Feature","geometry":{"type":"Point","coordinates":[37.6173,55.7558]},
+		"properties":{"full_credit_radius_km":50,"partial_credit_radius_km":250}}`}
+	if err := (mapGrader{}).Validate(moscow); err != nil {
+		t.Fatalf("ключ не прошел проверку: %v", err)
+	}
+	// Тверь примерно в 160 км от Москвы, между радиусами
+	tver := HaversineKm(GeoPoint{37.6173, 55.7558}, GeoPoint{35.9, 56.86})
+	partial := 1 - (tver-50)/(250-50)
+	checkGrades(t, mapGrader{}, moscow, []gradeCase{
+		{"в цели", `[37.6173, 55.7558]`, "", 1},
+		{"в радиусе полного балла", `[37.9, 55.8]`, "", 1},
+		{"частичный балл при all_or_nothing", `[35.9, 56.86]`, ScoringAllOrNothing, partial},
+		{"частичный балл при partial", `[35.9, 56.86]`, ScoringPartial, partial},
+		{"дальше радиуса частичного балла", `[30.31, 59.94]`, ScoringPartial, 0},
+		{"некорректный ответ", `"Москва"`, "", 0},
+	})
+
+	strict := &models.TestQuestion{AnswerKey: `{"type":"Feature","geometry":{"type":"Point","coordinates":[37.6173,55.7558]},"properties":{"full_credit_radius_km":50}}`}
+	checkGrades(t, mapGrader{}, strict, []gradeCase{
+		{"без радиуса частичного балла", `[35.9, 56.86]`, ScoringPartial, 0},
+	})
+
+	chukotkaKey := &models.TestQuestion{AnswerKey: `{"type":"Polygon","coordinates":[[[170,60],[-170,60],[-170,70],[170,70],[170,60]]]}`}
+	checkGrades(t, mapGrader{}, chukotkaKey, []gradeCase{
+		{"внутри полигона через 180°", `[-175, 65]`, "", 1},
+		{"вне полигона", `[150, 65]`, "", 0},
+	})
+}
+
+func TestMapGraderValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		ok   bool
+	}{
+		{"точка с радиусом", `{"type":"Feature","geometry":{"type":"Point","coordinates":[0,0]},"properties":{"full_credit_radius_km":10}}`, true},
+		{"точка без радиуса", `{"type":"Point","coordinates":[0,0]}`, false},
+		{"частичный радиус меньше полного", `{"type":"Feature","geometry":{"type":"Point","coordinates":[0,0]},"properties":{"full_credit_radius_km":10,"partial_credit_radius_km":5}}`, false},
+		{"отрицательный радиус", `{"type":"Feature","geometry":{"type":"Point","coordinates":[0,0]},"properties":{"full_credit_radius_km":-1}}`, false},
+		{"полигон", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`, true},
+		{"линия", `{"type":"LineString","coordinates":[[0,0],[1,1]]}`, false},
+	}
+	for _, tt := range tests {
+		err := (mapGrader{}).Validate(&models.TestQuestion{AnswerKey: tt.key})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ошибка %v", tt.name, err)
+		}
+	}
+}
+
+func TestNumericGrader(t *testing.T) {
+	q := &models.TestQuestion{AnswerKey: `{"value": 5642, "tolerance": 50}`}
+	checkGrades(t, numericGrader{}, q, []gradeCase{
+		{"точно", `5642`, "", 1},
+		{"на границе допуска", `5692`, "", 1},
+		{"за границей допуска", `5692.5`, "", 0},
+		{"ниже в пределах допуска", `5600`, "", 1},
+		{"строка с запятой", `"5641,5"`, "", 1},
+		{"строка с пробелами", `" 5642 "`, "", 1},
+		{"не число", `"много"`, "", 0},
+	})
+
+	exact := &models.TestQuestion{AnswerKey: `{"value": 0.5}`}
+	checkGrades(t, numericGrader{}, exact, []gradeCase{
+		{"без допуска точно", `0.5`, "", 1},
+		{"без допуска неточно", `0.51`, "", 0},
+	})
+
+	for key, ok := range map[string]bool{
+		`{"value": 0}`:                    true,
+		`{"value": 10, "tolerance": 1}`:   true,
+		`{"tolerance": 1}`:                false,
+		`{"value": 10, "tolerance": -1}`:  false,
+		`{"value": "10", "tolerance": 1}`: false,
+	} {
+		if err := (numericGrader{}).Validate(&models.TestQuestion{AnswerKey: key}); (err == nil) != ok {
+			t.Errorf("ключ %s: ошибка %v", key, err)
+		}
+	}
+}
+
+func TestTextGrader(t *testing.T) {
+	q := &models.TestQuestion{AnswerKey: `{"answers": ["Енисей", "р. Енисей"]}`}
+	checkGrades(t, textGrader{}, q, []gradeCase{
+		{"точно", `"Енисей"`, "", 1},
+		{"другой регистр", `"ЕНИСЕЙ"`, "", 1},
+		{"лишние пробелы", `"  р.   Енисей "`, "", 1},
+		{"синоним", `"р. енисей"`, "", 1},
+		{"другой ответ", `"Лена"`, "", 0},
+		{"пустой ответ", `"   "`, "", 0},
+		{"не строка", `42`, "", 0},
+	})
+
+	caseSensitive := &models.TestQuestion{AnswerKey: `{"answers": ["Озеро Онежское"], "case_sensitive": true}`}
+	checkGrades(t, textGrader{}, caseSensitive, []gradeCase{
+		{"с учетом регистра", `"Озеро Онежское"`, "", 1},
+		{"другой регистр при case_sensitive", `"озеро онежское"`, "", 0},
+	})
+
+	yoKey := &models.TestQuestion{AnswerKey: `{"answers": ["Орёл"]}`}
+	checkGrades(t, textGrader{}, yoKey, []gradeCase{
+		{"е вместо ё", `"Орел"`, "", 1},
+		{"ё в ответе", `"ОРЁЛ"`, "", 1},
+	})
+
+	for key, ok := range map[string]bool{
+		`{"answers": ["Волга"]}`:      true,
+		`{"answers": []}`:             false,
+		`{"answers": ["Волга", " "]}`: false,
+	} {
+		if err := (textGrader{}).Validate(&models.TestQuestion{AnswerKey: key}); (err == nil) != ok {
+			t.Errorf("ключ %s: ошибка %v", key, err)
+		}
+	}
+}
+
+func TestMatchingGrader(t *testing.T) {
+	q := &models.TestQuestion{
+		Options:      `["Россия","Франция","Япония"]`,
+		MatchOptions: `["Париж","Токио","Москва"]`,
+		AnswerKey:    `{"pairs": [2, 0, 1]}`,
+	}
+	if err := (matchingGrader{}).Validate(q); err != nil {
+		t.Fatalf("ключ не прошел проверку: %v", err)
+	}
+	checkGrades(t, matchingGrader{}, q, []gradeCase{
+		{"все пары", `[2, 0, 1]`, ScoringAllOrNothing, 1},
+		{"одна пара неверна", `[2, 1, 0]`, ScoringAllOrNothing, 0},
+		{"одна пара верна в partial", `[2, 1, 0]`, ScoringPartial, 1.0 / 3},
+		{"неполный ответ в partial", `[2, 0]`, ScoringPartial, 2.0 / 3},
+		{"неполный ответ", `[2, 0]`, ScoringAllOrNothing, 0},
+		{"лишние элементы", `[2, 0, 1, 1]`, ScoringAllOrNothing, 0},
+		{"не массив", `"2,0,1"`, ScoringPartial, 0},
+	})
+
+	for key, ok := range map[string]bool{
+		`{"pairs": [2, 0]}`:     false,
+		`{"pairs": [2, 0, 3]}`:  false,
+		`{"pairs": [0, 0, -1]}`: false,
+	} {
+		bad := *q
+		bad.AnswerKey = key
+		if err := (matchingGrader{}).Validate(&bad); (err == nil) != ok {
+			t.Errorf("ключ %s: ошибка %v", key, err)
+		}
+	}
+}
+
+func TestOrderingGrader(t *testing.T) {
+	q := &models.TestQuestion{
+		Options:   `["Кембрий","Юра","Мел","Ордовик"]`,
+		AnswerKey: `{"order": [0, 3, 1, 2]}`,
+	}
+	if err := (orderingGrader{}).Validate(q); err != nil {
+		t.Fatalf("ключ не прошел проверку: %v", err)
+	}
+	checkGrades(t, orderingGrader{}, q, []gradeCase{
+		{"верный порядок", `[0, 3, 1, 2]`, ScoringAllOrNothing, 1},
+		{"два элемента переставлены", `[0, 3, 2, 1]`, ScoringAllOrNothing, 0},
+		{"два элемента переставлены в partial", `[0, 3, 2, 1]`, ScoringPartial, 0.5},
+		{"обратный порядок в partial", `[2, 1, 3, 0]`, ScoringPartial, 0},
+	})
+
+	for key, ok := range map[string]bool{
+		`{"order": [0, 1, 2]}`:    false,
+		`{"order": [0, 1, 1, 2]}`: false,
+		`{"order": [0, 1, 2, 4]}`: false,
+	} {
+		bad := *q
+		bad.AnswerKey = key
+		if err := (orderingGrader{}).Validate(&bad); (err == nil) != ok {
+			t.Errorf("ключ %s: ошибка %v", key, err)
+		}
+	}
+}
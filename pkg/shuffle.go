@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"encoding/json"
+	"geografi-cheb/backend/models"
+	"math/rand"
+)
+
+// shuffledList возвращает, какой список вариантов перемешивается для типа вопроса:
+// "options", "match_options" или пустую строку, если вопрос не перемешивается
+func shuffledList(questionType string) string {
+	switch questionType {
+	case models.QuestionSingle, models.QuestionMultiple, models.QuestionOrdering:
+		return "options"
+	case models.QuestionMatching:
+		return "match_options"
+	}
+	return ""
+}
+
+// NewOptionOrder создает случайную перестановку вариантов вопроса.
+// Элемент perm[i] - исходный индекс варианта, показанного на позиции i.
+// Для вопросов без вариантов возвращает nil.
+func NewOptionOrder(q *models.TestQuestion, testType string) []int {
+	var list []string
+	switch shuffledList(q.EffectiveType(testType)) {
+	case "options":
+		list = parseStringList(q.Options)
+	case "match_options":
+		list = parseStringList(q.MatchOptions)
+	default:
+		return nil
+	}
+	if len(list) < 2 {
+		return nil
+	}
+	return rand.Perm(len(list))
+}
+
+// ApplyOptionOrder возвращает копию вопроса с вариантами в порядке показа
+func ApplyOptionOrder(q models.TestQuestion, testType string, perm []int) models.TestQuestion {
+	if len(perm) == 0 {
+		return q
+	}
+
+	reorder := func(data string) string {
+		list := parseStringList(data)
+		if len(list) != len(perm) {
+			return data
+		}
+		shuffled := make([]string, len(perm))
+		for shown, original := range perm {
+			shuffled[shown] = list[original]
+		}
+		result, _ := json.Marshal(shuffled)
+		return string(result)
+	}
+
+	switch shuffledList(q.EffectiveType(testType)) {
+	case "options":
+		q.Options = reorder(q.Options)
+	case "match_options":
+		q.MatchOptions = reorder(q.MatchOptions)
+	}
+	return q
+}
+
+// UnshuffleAnswer переводит индексы из ответа студента (в порядке показа) в исходные индексы вариантов
+func UnshuffleAnswer(q *models.TestQuestion, testType string, perm []int, raw json.RawMessage) json.RawMessage {
+	if len(perm) == 0 || shuffledList(q.EffectiveType(testType)) == "" {
+		return raw
+	}
+
+	toOriginal := func(shown int) int {
+		if shown < 0 || shown >= len(perm) {
+			return -1
+		}
+		return perm[shown]
+	}
+
+	var single int
+	if err := json.Unmarshal(raw, &single); err == nil {
+		result, _ := json.Marshal(toOriginal(single))
+		return result
+	}
+
+	var many []int
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return raw
+	}
+	for i, shown := range many {
+		many[i] = toOriginal(shown)
+	}
+	result, _ := json.Marshal(many)
+	return result
+}
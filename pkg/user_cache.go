@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"errors"
+	"geografi-cheb/backend/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionInactive - сессия завершена, истекла или принадлежит другому пользователю
+var ErrSessionInactive = errors.New("сессия не активна")
+
+// UserCache кэширует пользователей вместе с их активными сессиями для проверки запросов на короткое время.
+// После изменения роли, статуса, версии токенов или завершения сессии запись нужно сбросить через Invalidate;
+// в остальных случаях (например, на других экземплярах сервера) изменения видны не позже чем через TTL.
+type UserCache struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu         sync.Mutex
+	entries    map[uint]userCacheEntry
+	generation uint64 // Растет при каждом Invalidate
+}
+
+type userCacheEntry struct {
+	user      models.User
+	sessions  map[uint]time.Time // ID активной сессии -> срок ее действия
+	expiresAt time.Time
+}
+
+// sessionActive проверяет, что сессия есть среди активных и еще не истекла
+func (e userCacheEntry) sessionActive(sessionID uint, now time.Time) bool {
+	expiresAt, ok := e.sessions[sessionID]
+	return ok && now.Before(expiresAt)
+}
+
+// NewUserCache создает кэш пользователей; при ttl <= 0 каждый запрос читает БД
+func NewUserCache(db *gorm.DB, ttl time.Duration) *UserCache {
+	return &UserCache{db: db, ttl: ttl, entries: make(map[uint]userCacheEntry)}
+}
+
+// GetActive возвращает пользователя по ID, если его сессия sessionID активна.
+// Для удаленного пользователя возвращает gorm.ErrRecordNotFound, для завершенной сессии - ErrSessionInactive.
+// Сессии, которой нет в кэше (например, открытой после чтения), ищет повторным чтением БД.
+func (c *UserCache) GetActive(userID, sessionID uint) (models.User, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if !ok || !now.Before(entry.expiresAt) || !entry.sessionActive(sessionID, now) {
+		var err error
+		if entry, err = c.load(userID, now); err != nil {
+			return models.User{}, err
+		}
+	}
+	if !entry.sessionActive(sessionID, now) {
+		return models.User{}, ErrSessionInactive
+	}
+	return entry.user, nil
+}
+
+// load читает пользователя и его активные сессии и сохраняет их в кэш
+func (c *UserCache) load(userID uint, now time.Time) (userCacheEntry, error) {
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	var user models.User
+	if err := c.db.First(&user, userID).Error; err != nil {
+		return userCacheEntry{}, err
+	}
+	var sessions []models.Session
+	if err := c.db.Select("id", "expires_at").
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Find(&sessions).Error; err != nil {
+		return userCacheEntry{}, err
+	}
+	entry := userCacheEntry{user: user, sessions: make(map[uint]time.Time, len(sessions)), expiresAt: now.Add(c.ttl)}
+	for _, session := range sessions {
+		entry.sessions[session.ID] = session.ExpiresAt
+	}
+
+	// Не сохраняем, если во время чтения кэш сбрасывали: прочитанные данные могли устареть
+	c.mu.Lock()
+	if c.ttl > 0 && c.generation == generation {
+		c.entries[userID] = entry
+	}
+	c.mu.Unlock()
+	return entry, nil
+}
+
+// Invalidate сбрасывает пользователя и его сессии из кэша
+func (c *UserCache) Invalidate(id uint) {
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.generation++
+	c.mu.Unlock()
+}
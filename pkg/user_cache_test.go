@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"errors"
+	"geografi-cheb/backend/models"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestUserCacheSessions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:usercache?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "Иван", Email: "ivan@example.com", Password: "-", Role: models.RoleStudent}
+	db.Create(&user)
+	first := models.Session{UserID: user.ID, RefreshHash: "first", ExpiresAt: time.Now().Add(time.Hour)}
+	db.Create(&first)
+
+	cache := NewUserCache(db, time.Hour)
+	if _, err := cache.GetActive(user.ID, first.ID); err != nil {
+		t.Fatalf("активная сессия: %v", err)
+	}
+
+	// Сессия, открытая после чтения кэша, находится повторным чтением
+	second := models.Session{UserID: user.ID, RefreshHash: "second", ExpiresAt: time.Now().Add(time.Hour)}
+	db.Create(&second)
+	if _, err := cache.GetActive(user.ID, second.ID); err != nil {
+		t.Fatalf("новая сессия: %v", err)
+	}
+
+	// Завершенная сессия видна из кэша до сброса записи
+	db.Model(&first).Update("revoked_at", time.Now())
+	if _, err := cache.GetActive(user.ID, first.ID); err != nil {
+		t.Fatalf("сессия до сброса кэша: %v", err)
+	}
+	cache.Invalidate(user.ID)
+	if _, err := cache.GetActive(user.ID, first.ID); !errors.Is(err, ErrSessionInactive) {
+		t.Fatalf("завершенная сессия: %v", err)
+	}
+	if _, err := cache.GetActive(user.ID, second.ID); err != nil {
+		t.Fatalf("другая сессия после сброса: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// xlsxSheet разбор листа книги, записанной WriteXLSX
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSXFile возвращает содержимое файла из книги
+func readXLSXFile(t *testing.T, book []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+	if err != nil {
+		t.Fatalf("книга не открывается как zip: %v", err)
+	}
+	for _, file := range zr.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	t.Fatalf("в книге нет %s", name)
+	return ""
+}
+
+func TestWriteXLSXRoundTrip(t *testing.T) {
+	wide := make([]interface{}, 28)
+	wide[27] = "AB"
+	rows := [][]interface{}{
+		{"name", "score"},
+		{`<b>Иванов & "сын"</b>`, 87.5},
+		{"=SUM(A1:A2)", 5, nil, uint(7)},
+		wide,
+	}
+
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, "Журнал: 9[А]/класс", rows); err != nil {
+		t.Fatal(err)
+	}
+	book := buf.Bytes()
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		readXLSXFile(t, book, name)
+	}
+	if workbook := readXLSXFile(t, book, "xl/workbook.xml"); !strings.Contains(workbook, `name="Журнал_ 9_А__класс"`) {
+		t.Errorf("имя листа не очищено: %s", workbook)
+	}
+
+	raw := readXLSXFile(t, book, "xl/worksheets/sheet1.xml")
+	if strings.Contains(raw, "<b>") {
+		t.Fatalf("текст ячейки не экранирован: %s", raw)
+	}
+	var sheet xlsxSheet
+	if err := xml.Unmarshal([]byte(raw), &sheet); err != nil {
+		t.Fatalf("лист не разбирается как XML: %v", err)
+	}
+	if len(sheet.Rows) != len(rows) {
+		t.Fatalf("строк %d, ожидалось %d", len(sheet.Rows), len(rows))
+	}
+
+	header := sheet.Rows[0].Cells
+	if len(header) != 2 || header[0].Style != "1" || header[0].Inline != "name" {
+		t.Errorf("заголовок: %+v", header)
+	}
+	student := sheet.Rows[1].Cells
+	if student[0].Type != "inlineStr" || student[0].Inline != `<b>Иванов & "сын"</b>` {
+		t.Errorf("текстовая ячейка: %+v", student[0])
+	}
+	if student[1].Ref != "B2" || student[1].Type != "" || student[1].Value != "87.5" {
+		t.Errorf("числовая ячейка: %+v", student[1])
+	}
+	mixed := sheet.Rows[2].Cells
+	if len(mixed) != 3 || mixed[1].Value != "5" || mixed[2].Ref != "D3" || mixed[2].Value != "7" {
+		t.Errorf("пустая ячейка не пропущена или числа записаны неверно: %+v", mixed)
+	}
+	if last := sheet.Rows[3].Cells; len(last) != 1 || last[0].Ref != "AB4" {
+		t.Errorf("колонка после Z: %+v", last)
+	}
+}